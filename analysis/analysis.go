@@ -0,0 +1,237 @@
+// Package analysis 以库的方式运行评论用户手机品牌分析
+//
+// 每个 Analyzer 只持有自己的配置，同一进程中可以用不同的设置分析多个目标用户：
+//
+//	cfg := config.Default()
+//	cfg.UID, cfg.Cookie = "2397417584", cookie
+//	analyzer, err := analysis.New(cfg)
+//	if err != nil { ... }
+//	defer analyzer.Close()
+//	result, err := analyzer.Run(ctx)
+package analysis
+
+import (
+	"comment_phone_analyse/config"
+	"comment_phone_analyse/export"
+	"comment_phone_analyse/internal/client"
+	"comment_phone_analyse/internal/logging"
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/services"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 分析结果中使用的数据类型
+type (
+	User         = models.UserInfo        // 一个评论用户及其设备、性别、IP属地
+	Statistics   = models.PhoneStatistics // 品牌、性别、地区的人数统计
+	BrandCount   = models.StatisticsData  // 单个品牌的人数
+	RunInfo      = models.RunInfo         // 一次分析的目标、时间与样本量
+	Progress     = services.Progress      // 分析进行中的进度
+	CookieHealth = client.CookieHealth    // 一个 Cookie 的请求统计与状态
+	ProxyHealth  = client.ProxyHealth     // 一个代理的请求统计与状态
+)
+
+// Result 一次分析的结果
+type Result struct {
+	Run        RunInfo        `json:"run"`
+	Statistics *Statistics    `json:"statistics"`
+	Known      []BrandCount   `json:"known"`                  // 已知品牌，按人数降序
+	Unknown    []BrandCount   `json:"unknown"`                // 未识别的设备，按人数降序
+	OutputDir  string         `json:"output_dir"`             // 用户记录所在的目录
+	Summary    string         `json:"summary"`                // 文本摘要
+	Cookies    []CookieHealth `json:"cookies,omitempty"`      // 各 Cookie 的请求统计与状态
+	Proxies    []ProxyHealth  `json:"proxies,omitempty"`      // 各代理的请求统计与状态
+	Drift      map[string]int `json:"schema_drift,omitempty"` // 各接口响应结构异常的次数
+}
+
+// Analyzer 一个目标用户的分析器
+type Analyzer struct {
+	cfg      *config.Config
+	analyzer *services.AnalyzerService
+	settings settings
+	users    int       // 本次运行已处理的用户数，用于触发快照
+	logFile  io.Closer // run.log，打开失败时为 nil
+}
+
+// settings 通过 Option 设置的回调
+type settings struct {
+	onUser     func(*User)
+	onSnapshot func(*Result)
+}
+
+// Option 分析器的可选设置
+type Option func(*settings)
+
+// WithUserHandler 每处理完一个用户时调用 handler，用于实时接收结果
+//
+// handler 在抓取协程中同步调用，耗时操作应自行转交给其他协程。
+func WithUserHandler(handler func(*User)) Option {
+	return func(s *settings) {
+		s.onUser = handler
+	}
+}
+
+// WithSnapshotHandler 每处理完配置中 snapshot_every 个用户时以当前结果调用 handler，用于定期落盘中间结果
+//
+// handler 在抓取协程中同步调用，调用期间抓取暂停。
+func WithSnapshotHandler(handler func(*Result)) Option {
+	return func(s *settings) {
+		s.onSnapshot = handler
+	}
+}
+
+// New 校验配置并创建分析器，配置中的品牌映射文件无法读取时返回错误
+func New(cfg *config.Config, options ...Option) (*Analyzer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	mapping, err := models.LoadPhoneMapping(cfg.BrandMappingFile)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Analyzer{cfg: cfg}
+	for _, option := range options {
+		option(&a.settings)
+	}
+	logger := a.openRunLog().With("uid", cfg.UID)
+
+	opts := services.AnalyzerOptions{
+		Crawl:         crawlOptions(cfg),
+		OutputDir:     cfg.OutputDir,
+		RecordFormats: cfg.RecordFormats,
+		StoragePath:   cfg.StoragePath,
+		OnUser:        a.onUser,
+		Logger:        logger,
+	}
+	weibo := services.NewWeiboService(services.WeiboOptions{
+		Cookies: cfg.CookiePool(),
+		Client: client.Options{
+			Budget:    cfg.CookieBudget,
+			Cooldown:  time.Duration(cfg.CookieCooldown) * time.Second,
+			Headers:   cfg.Headers,
+			Proxies:   cfg.Proxies,
+			ProxyMode: cfg.ProxyMode,
+		},
+		MaxDrift:     cfg.MaxSchemaDrift,
+		PhoneMapping: mapping,
+		Logger:       logger,
+	})
+	a.analyzer = services.NewAnalyzerService(weibo, opts)
+	return a, nil
+}
+
+// openRunLog 创建 <输出目录>/<UID>/run.log，日志同时写入默认日志与该文件；失败时只使用默认日志
+func (a *Analyzer) openRunLog() *slog.Logger {
+	dir := filepath.Join(a.cfg.OutputDir, a.cfg.UID)
+	// 配置已校验，日志级别不会出错
+	level, _ := logging.ParseLevel(a.cfg.LogLevel)
+	err := os.MkdirAll(dir, 0755)
+	if err == nil {
+		var logger *slog.Logger
+		if logger, a.logFile, err = logging.OpenFile(slog.Default(), filepath.Join(dir, export.RunLogFile), a.cfg.LogFormat, level); err == nil {
+			return logger
+		}
+	}
+	slog.Warn("创建运行日志失败，只输出到控制台", "err", err)
+	return slog.Default()
+}
+
+// onUser 转发用户回调，并按 snapshot_every 触发快照
+func (a *Analyzer) onUser(user *User) {
+	if a.settings.onUser != nil {
+		a.settings.onUser(user)
+	}
+	a.users++
+	if a.settings.onSnapshot != nil && a.cfg.SnapshotEvery > 0 && a.users%a.cfg.SnapshotEvery == 0 {
+		a.settings.onSnapshot(a.Result())
+	}
+}
+
+// crawlOptions 从配置中取出抓取与采样选项
+func crawlOptions(cfg *config.Config) services.CrawlOptions {
+	// 配置已校验，时间范围不会出错
+	since, until, _ := cfg.PostWindow()
+	return services.CrawlOptions{
+		UID:             cfg.UID,
+		Limit:           cfg.Limit,
+		Interval:        cfg.Interval,
+		SampleStrategy:  cfg.SampleStrategy,
+		SamplePosts:     cfg.SamplePosts,
+		SamplePool:      cfg.SamplePool,
+		SingleLimit:     cfg.SingleLimit,
+		SinglePageLimit: cfg.SinglePageLimit,
+		MaxFailures:     cfg.MaxFailures,
+		PostIDs:         cfg.PostIDs,
+		Filter: services.PostFilter{
+			MinComments: cfg.MinComments,
+			Since:       since,
+			Until:       until,
+			Keyword:     cfg.PostKeyword,
+		},
+	}
+}
+
+// Run 抓取评论用户并统计，返回结果；同一分析器重复调用会重新开始统计
+//
+// ctx 取消时中止进行中的请求与等待，返回已处理部分的结果以及 ctx.Err()；
+// 响应结构异常次数超过 max_schema_drift 时同样中止，返回已处理部分的结果与解析错误。
+func (a *Analyzer) Run(ctx context.Context) (*Result, error) {
+	a.users = 0
+	a.analyzer.AnalyzeUserPhones(ctx)
+	if err := a.analyzer.Err(); err != nil {
+		return a.Result(), err
+	}
+	return a.Result(), ctx.Err()
+}
+
+// Result 返回当前的统计结果，分析进行中时为已处理部分的结果
+func (a *Analyzer) Result() *Result {
+	return &Result{
+		Run:        a.analyzer.GetRunInfo(),
+		Statistics: a.analyzer.GetStatistics(),
+		Known:      a.analyzer.GetKnownBrandStats(),
+		Unknown:    a.analyzer.GetUnknownBrandStats(),
+		OutputDir:  a.analyzer.GetOutputDir(),
+		Summary:    a.analyzer.GetSummary(),
+		Cookies:    a.analyzer.GetCookieHealth(),
+		Proxies:    a.analyzer.GetProxyHealth(),
+		Drift:      a.analyzer.GetSchemaDrift(),
+	}
+}
+
+// Progress 返回当前进度，可在 Run 进行中从其他协程调用
+func (a *Analyzer) Progress() Progress {
+	return a.analyzer.GetProgress(3)
+}
+
+// Config 返回分析器使用的配置
+func (a *Analyzer) Config() *config.Config {
+	return a.cfg
+}
+
+// CheckCookie 检查配置中的 Cookie 能否获取目标用户资料，不创建输出文件
+func CheckCookie(ctx context.Context, cfg *config.Config) (*User, error) {
+	weibo := services.NewWeiboService(services.WeiboOptions{
+		Cookie: cfg.Cookie,
+		Client: client.Options{Headers: cfg.Headers, Proxies: cfg.Proxies, ProxyMode: cfg.ProxyMode},
+	})
+	return weibo.CheckCookie(ctx, cfg.UID)
+}
+
+// Close 关闭用户记录文件、数据库与运行日志
+func (a *Analyzer) Close() error {
+	err := a.analyzer.Close()
+	if a.logFile != nil {
+		if closeErr := a.logFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		a.logFile = nil
+	}
+	return err
+}
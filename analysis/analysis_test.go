@@ -0,0 +1,52 @@
+package analysis
+
+import (
+	"comment_phone_analyse/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+
+	newConfig := func(uid string) *config.Config {
+		cfg := config.Default()
+		cfg.UID, cfg.Cookie, cfg.OutputDir = uid, "SUB=x", dir
+		return cfg
+	}
+
+	// 同一进程中的两个分析器互不影响
+	first, err := New(newConfig("1"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer first.Close()
+	second, err := New(newConfig("2"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer second.Close()
+
+	for uid, analyzer := range map[string]*Analyzer{"1": first, "2": second} {
+		result := analyzer.Result()
+		if result.Run.UID != uid || result.OutputDir != filepath.Join(dir, uid) {
+			t.Errorf("Result() = uid %s dir %s, want uid %s dir %s", result.Run.UID, result.OutputDir, uid, filepath.Join(dir, uid))
+		}
+	}
+
+	noCookie := newConfig("3")
+	noCookie.Cookie = ""
+	if _, err := New(noCookie); err == nil {
+		t.Error("New() without cookie should fail")
+	}
+
+	badMapping := newConfig("4")
+	badMapping.BrandMappingFile = filepath.Join(dir, "mapping.json")
+	if err := os.WriteFile(badMapping.BrandMappingFile, []byte(`["not an object"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(badMapping); err == nil {
+		t.Error("New() with an invalid mapping file should fail")
+	}
+}
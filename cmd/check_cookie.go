@@ -0,0 +1,25 @@
+package main
+
+import (
+	"comment_phone_analyse/analysis"
+	"context"
+	"fmt"
+)
+
+// runCheckCookie 用配置中的 Cookie 请求目标用户资料，失效时以认证错误退出
+func runCheckCookie(args []string) error {
+	flags := newFlagSet("check-cookie", "[参数]")
+	configFlags := addConfigFlags(flags)
+	flags.Parse(args)
+
+	cfg, err := configFlags.load()
+	if err != nil {
+		return err
+	}
+	user, err := analysis.CheckCookie(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Cookie 可用，已获取用户 %s（%s）的资料\n", user.UserName, user.Id)
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"comment_phone_analyse/config"
+	"comment_phone_analyse/internal/logging"
+	"comment_phone_analyse/internal/utils"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// newFlagSet 创建子命令参数集，-h/--help 时输出用法
+func newFlagSet(name, argsUsage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "用法: main %s %s\n\n参数:\n", name, argsUsage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// configFlags 覆盖配置文件的通用参数
+type configFlags struct {
+	flags      *flag.FlagSet
+	path       string
+	uid        string
+	limit      int
+	output     string
+	interval   int
+	cookieFile string
+	proxy      string
+	verbose    bool
+}
+
+// addConfigFlags 注册 --config、--uid、--limit、--output、--interval、--cookie-file、--proxy、--verbose（及其别名 --debug）参数
+func addConfigFlags(flags *flag.FlagSet) *configFlags {
+	f := &configFlags{flags: flags}
+	flags.StringVar(&f.path, "config", "", "配置文件路径，为空时依次查找 config.json 等默认位置")
+	flags.StringVar(&f.uid, "uid", "", "目标用户ID，覆盖配置文件")
+	flags.IntVar(&f.limit, "limit", 0, "统计的用户数量，覆盖配置文件")
+	flags.StringVar(&f.output, "output", "", "输出目录，覆盖配置文件")
+	flags.IntVar(&f.interval, "interval", 0, "请求间隔秒数，覆盖配置文件")
+	flags.StringVar(&f.cookieFile, "cookie-file", "", "从该文件读取 Cookie，\"-\" 表示标准输入")
+	flags.StringVar(&f.proxy, "proxy", "", "HTTP 或 SOCKS5 代理，多个用逗号分隔，覆盖配置文件")
+	flags.BoolVar(&f.verbose, "verbose", false, "输出 debug 级别的日志，包括每个请求的 Cookie、代理与耗时")
+	flags.BoolVar(&f.verbose, "debug", false, "同 --verbose（已弃用）")
+	return f
+}
+
+// load 加载配置文件，并用命令行中显式给出的参数覆盖，然后按配置初始化日志
+func (f *configFlags) load() (*config.Config, error) {
+	set := make(map[string]bool)
+	f.flags.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+
+	cfg, err := config.LoadConfigFrom(f.path, func(cfg *config.Config) {
+		if set["uid"] {
+			cfg.UID = f.uid
+		}
+		if set["limit"] {
+			cfg.Limit = f.limit
+		}
+		if set["output"] {
+			cfg.OutputDir = f.output
+		}
+		if set["interval"] {
+			cfg.Interval = f.interval
+		}
+		if set["cookie-file"] {
+			cfg.CookieFile = f.cookieFile
+		}
+		if set["proxy"] {
+			cfg.Proxies = nil
+			for _, proxy := range strings.Split(f.proxy, ",") {
+				if proxy = strings.TrimSpace(proxy); proxy != "" {
+					cfg.Proxies = append(cfg.Proxies, proxy)
+				}
+			}
+		}
+		if (set["verbose"] || set["debug"]) && f.verbose {
+			cfg.LogLevel = "debug"
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 日志写入标准错误，标准输出只留给结果
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err == nil {
+		err = logging.Setup(cfg.LogFormat, level)
+	}
+	if err != nil {
+		return nil, utils.NewConfigError("初始化日志失败", err)
+	}
+	return cfg, nil
+}
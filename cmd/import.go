@@ -0,0 +1,90 @@
+package main
+
+import (
+	"comment_phone_analyse/internal/legacy"
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/storage"
+	"fmt"
+	"log/slog"
+)
+
+// 未指定路径时扫描的旧版输出目录
+var defaultLegacyPaths = []string{"statistics-data", "output"}
+
+// runImport 导入旧版统计输出：按当前品牌映射重新分类后写入数据库或 JSONL
+func runImport(args []string) error {
+	flags := newFlagSet("import", "[-db 数据库] [-out 目录] [-mapping 映射文件] [文件或目录...]")
+	dbPath := flags.String("db", "", "写入的 SQLite 数据库路径，为空时导出 JSONL")
+	outDir := flags.String("out", "./output/legacy", "JSONL 导出目录")
+	mappingFile := flags.String("mapping", "", "追加的品牌映射文件")
+	flags.Parse(args)
+
+	mapping, err := models.LoadPhoneMapping(*mappingFile)
+	if err != nil {
+		return err
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = defaultLegacyPaths
+	}
+	files, err := legacy.Collect(paths)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Println("没有找到可导入的旧版输出")
+		return nil
+	}
+
+	var store *storage.Store
+	if *dbPath != "" {
+		if store, err = storage.Open(*dbPath); err != nil {
+			return err
+		}
+		defer store.Close()
+	}
+
+	var lastErr error
+	failed := 0
+	for _, file := range files {
+		dataset, err := legacy.ParseFile(file)
+		if err != nil {
+			slog.Warn("跳过无法读取的文件", "file", file, "err", err)
+			lastErr = err
+			failed++
+			continue
+		}
+		dataset = dataset.Reclassify(mapping)
+
+		if store != nil {
+			runID, imported, err := legacy.ImportToStore(store, dataset)
+			switch {
+			case err != nil:
+				slog.Error("导入失败", "file", file, "err", err)
+				lastErr = err
+				failed++
+			case !imported:
+				fmt.Printf("%s 已导入过（运行 %d），跳过\n", file, runID)
+			default:
+				fmt.Printf("%s -> 运行 %d（%s，%d 个用户）\n", file, runID, dataset.Target, dataset.Size())
+			}
+			continue
+		}
+
+		filename, err := legacy.WriteJSONL(*outDir, dataset)
+		if err != nil {
+			slog.Error("导出失败", "file", file, "err", err)
+			lastErr = err
+			failed++
+			continue
+		}
+		fmt.Printf("%s -> %s（%s，%d 个用户）\n", file, filename, dataset.Target, dataset.Size())
+	}
+
+	fmt.Printf("导入完成: %d 个文件，失败 %d 个\n", len(files), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d 个文件导入失败: %w", failed, lastErr)
+	}
+	return nil
+}
@@ -1,98 +1,330 @@
 package main
 
 import (
+	"comment_phone_analyse/analysis"
 	"comment_phone_analyse/config"
 	"comment_phone_analyse/export"
 	"comment_phone_analyse/internal/models"
-	"comment_phone_analyse/internal/services"
+	"comment_phone_analyse/internal/server"
+	"comment_phone_analyse/internal/utils"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
+// command 子命令
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// errUsage 命令行参数错误，以退出码 2 退出
+var errUsage = errors.New("参数错误")
+
+// commands 全部子命令，按帮助中的显示顺序排列
+var commands = []command{
+	{"analyze", "抓取评论用户并统计手机品牌（默认命令）", runAnalyze},
+	{"report", "根据已完成的输出目录或数据库中的运行重新生成摘要与图表", runReport},
+	{"compare", "对比两次运行的品牌分布", runCompare},
+	{"schedule", "按配置中的 cron 表达式定时分析并更新趋势图", runSchedule},
+	{"trend", "根据历史运行生成品牌占比趋势图", runTrend},
+	{"import", "导入旧版统计输出", runImport},
+	{"reclassify", "按当前品牌映射重新分类已完成的输出", runReclassify},
+	{"triage", "汇总未识别的设备来源并给出映射建议", runTriage},
+	{"check-cookie", "检查配置中的 Cookie 是否可用", runCheckCookie},
+	{"serve", "本地仪表盘：实时查看分析进度与统计，提供 JSON 接口、分析任务接口与输出目录浏览", runServe},
+}
+
 func main() {
-	// 初始化全局配置
-	if err := config.InitGlobalConfig(); err != nil {
-		log.Fatalf("加载配置失败: %v", err)
+	os.Exit(run(os.Args[1:]))
+}
+
+// run 执行子命令并返回退出码
+func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			usage(os.Stdout)
+			return utils.ExitOK
+		}
+	}
+
+	// 不带子命令时执行 analyze，兼容原来的用法
+	name := "analyze"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(args); err != nil {
+				if errors.Is(err, errUsage) {
+					fmt.Fprintln(os.Stderr, err)
+					return utils.ExitUsage
+				}
+				slog.Error("命令失败", "command", name, "err", err)
+				return utils.ExitCode(err)
+			}
+			return utils.ExitOK
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "未知的子命令: %s\n\n", name)
+	usage(os.Stderr)
+	return utils.ExitUsage
+}
+
+// usage 输出全部子命令与退出码说明
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "用法: main [子命令] [参数]")
+	fmt.Fprintln(w, "\n子命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(w, "\n使用 main <子命令> -h 查看各子命令的参数。")
+	fmt.Fprintln(w, "\n退出码:")
+	fmt.Fprintf(w, "  %d 成功  %d 未分类错误  %d 参数错误  %d 配置错误  %d 网络错误\n",
+		utils.ExitOK, utils.ExitFailure, utils.ExitUsage, utils.ExitConfig, utils.ExitNetwork)
+	fmt.Fprintf(w, "  %d 认证失败（Cookie 失效）  %d 请求过于频繁  %d 解析错误  %d 数据不存在  %d 导出错误\n",
+		utils.ExitAuth, utils.ExitRateLimit, utils.ExitParse, utils.ExitNotFound, utils.ExitExport)
+}
+
+// runAnalyze 抓取评论用户并统计手机品牌分布
+func runAnalyze(args []string) error {
+	flags := newFlagSet("analyze", "[参数]")
+	configFlags := addConfigFlags(flags)
+	flags.Parse(args)
 
-	cfg := config.GetGlobalConfig()
+	cfg, err := configFlags.load()
+	if err != nil {
+		return err
+	}
 	cfg.Print()
 
-	// 创建服务
-	weiboService := services.NewWeiboService()
-	analyzerService := services.NewAnalyzerService(weiboService)
-	defer analyzerService.Close() // 确保资源释放
+	// 收到退出信号时取消抓取，导出已处理的部分后正常退出；再次发送信号则立即退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	return analyze(ctx, cfg, nil)
+}
+
+// analyze 执行一次分析并导出结果，ctx 取消时导出已处理的部分；live 不为 nil 时向仪表盘实时推送
+func analyze(ctx context.Context, cfg *config.Config, live *server.Server) error {
+	// 每 snapshot_every 个用户重新生成一次摘要与图表
+	options := []analysis.Option{analysis.WithSnapshotHandler(func(result *analysis.Result) {
+		writeSnapshot(cfg, result)
+	})}
+	if live != nil {
+		options = append(options, analysis.WithUserHandler(live.AddUser))
+	}
 
-	setupGracefulShutdown(analyzerService)
+	analyzer, err := analysis.New(cfg, options...)
+	if err != nil {
+		return err
+	}
+	defer analyzer.Close() // 确保资源释放
+	if live != nil {
+		live.Watch(analyzer)
+		defer live.Finish()
+	}
 
 	// 开始分析
 	fmt.Println("开始分析...")
-	analyzerService.AnalyzeUserPhones()
+	done := make(chan struct{})
+	go printProgress(analyzer, time.Duration(cfg.ProgressInterval)*time.Second, done)
+	result, err := analyzer.Run(ctx)
+	close(done)
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Println("\n\n收到退出信号，正在导出已处理的部分...")
+		err = nil
+	case errors.Is(err, utils.ErrSchemaDrift):
+		// 导出已处理的部分后仍以解析错误退出
+		fmt.Printf("\n\n%v\n正在导出已处理的部分...\n", err)
+	case err != nil:
+		return err
+	}
 
 	// 打印结果
-	printResults(analyzerService)
-	convertDataToChart(analyzerService)
+	printResults(result)
+	printCookieHealth(result.Cookies)
+	printProxyHealth(result.Proxies)
+	printSchemaDrift(result.Drift)
+	convertDataToChart(cfg, result)
+	return err
 }
 
-func convertDataToChart(analyzerService *services.AnalyzerService) {
-	cfg := config.GetGlobalConfig()
+func convertDataToChart(cfg *config.Config, result *analysis.Result) {
 	// 导出图表到用户专属目录
-	userOutputDir := analyzerService.GetOutputDir()
+	userOutputDir := result.OutputDir
 	chartExporter := export.NewChartExporter(cfg.UID, userOutputDir)
-	fmt.Println("\n开始导出图表...")
-	knownStats := analyzerService.GetKnownBrandStats()
+
+	// 记录运行信息，供离线重新分类时生成仪表盘
+	run := result.Run
+	if err := export.WriteRunInfo(userOutputDir, run); err != nil {
+		slog.Error("保存运行信息失败", "err", err)
+	}
+
+	exportCharts(os.Stdout, chartExporter, result.Statistics, run)
+
+	// 导出 stat_summary SQL
+	if cfg.SQLDialect != "" {
+		if err := exportSQL(chartExporter, userOutputDir, cfg); err != nil {
+			slog.Error("导出SQL失败", "err", err)
+		} else {
+			fmt.Println("SQL导出完成!")
+		}
+	}
+
+	// 打印摘要
+	fmt.Println("\n" + result.Summary)
+	fmt.Printf("所有文件已保存到目录: %s\n", userOutputDir)
+}
+
+// exportCharts 根据统计数据导出全部图表与摘要，进度信息写入 w，失败信息写入日志
+func exportCharts(w io.Writer, chartExporter *export.ChartExporter, allStats *models.PhoneStatistics, run models.RunInfo) {
+	fmt.Fprintln(w, "\n开始导出图表...")
+
+	// 饼图与柱状图只展示已知品牌
+	var knownStats []models.StatisticsData
+	for _, stat := range models.SortedCounts(allStats.BrandCounts) {
+		if models.IsKnownBrand(stat.PhoneType) {
+			knownStats = append(knownStats, stat)
+		}
+	}
 
 	// 导出饼图
 	if err := chartExporter.ExportPieChart(knownStats); err != nil {
-		log.Printf("导出饼图失败: %v", err)
+		slog.Error("导出饼图失败", "err", err)
 	} else {
-		fmt.Println("饼图导出完成!")
+		fmt.Fprintln(w, "饼图导出完成!")
 	}
 
 	// 导出柱状图
 	if err := chartExporter.ExportBarChart(knownStats); err != nil {
-		log.Printf("导出柱状图失败: %v", err)
+		slog.Error("导出柱状图失败", "err", err)
 	} else {
-		fmt.Println("柱状图导出完成!")
+		fmt.Fprintln(w, "柱状图导出完成!")
 	}
 
-	// 导出摘要
-	summaryExporter := export.NewChartExporter(cfg.UID, userOutputDir)
+	// 导出性别与地区图表
+	if err := chartExporter.ExportGenderChart(allStats); err != nil {
+		slog.Error("导出性别图表失败", "err", err)
+	} else {
+		fmt.Fprintln(w, "性别图表导出完成!")
+	}
 
-	// 获取所有统计数据（包括未知机型）
-	allStats := analyzerService.GetStatistics()
-	var allStatsData []models.StatisticsData
-	for phoneType, count := range allStats.BrandCounts {
-		allStatsData = append(allStatsData, models.StatisticsData{
-			PhoneType: phoneType,
-			Count:     count,
-		})
+	if err := chartExporter.ExportRegionChart(allStats); err != nil {
+		slog.Error("导出地区图表失败", "err", err)
+	} else {
+		fmt.Fprintln(w, "地区图表导出完成!")
 	}
 
-	if err := summaryExporter.ExportSummary(allStatsData); err != nil {
-		log.Printf("导出摘要失败: %v", err)
+	if err := chartExporter.ExportMapChart(allStats); err != nil {
+		slog.Error("导出地图失败", "err", err)
 	} else {
-		fmt.Println("摘要导出完成!")
+		fmt.Fprintln(w, "地图导出完成!")
 	}
 
-	// 打印摘要
-	fmt.Println("\n" + analyzerService.GetSummary())
-	fmt.Printf("所有文件已保存到目录: %s\n", userOutputDir)
+	// 导出汇总仪表盘
+	if err := chartExporter.ExportDashboard(allStats, run); err != nil {
+		slog.Error("导出仪表盘失败", "err", err)
+	} else {
+		fmt.Fprintln(w, "仪表盘导出完成!")
+	}
+
+	// 导出摘要
+	if err := chartExporter.ExportSummary(allStats); err != nil {
+		slog.Error("导出摘要失败", "err", err)
+	} else {
+		fmt.Fprintln(w, "摘要导出完成!")
+	}
+}
+
+// writeSnapshot 将已处理部分的运行信息、摘要与图表写入输出目录
+func writeSnapshot(cfg *config.Config, result *analysis.Result) {
+	writeResult(cfg, result)
+	slog.Info("已更新中间结果", "users", result.Statistics.UserCount, "dir", result.OutputDir)
+}
+
+// writeResult 不输出进度信息地写入运行信息、摘要与图表
+func writeResult(cfg *config.Config, result *analysis.Result) {
+	if err := export.WriteRunInfo(result.OutputDir, result.Run); err != nil {
+		slog.Error("保存运行信息失败", "err", err)
+	}
+	exportCharts(io.Discard, export.NewChartExporter(cfg.UID, result.OutputDir), result.Statistics, result.Run)
+}
+
+// runJob 执行一个 HTTP 提交的任务，结束或取消时将结果写入任务目录
+func runJob(ctx context.Context, cfg *config.Config) (*analysis.Result, error) {
+	analyzer, err := analysis.New(cfg, analysis.WithSnapshotHandler(func(result *analysis.Result) {
+		writeResult(cfg, result)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	defer analyzer.Close()
+
+	result, err := analyzer.Run(ctx)
+	writeResult(cfg, result)
+	return result, err
+}
+
+// printProgress 每隔 interval 打印一次进度，done 关闭时返回；interval 为 0 时不打印
+func printProgress(analyzer *analysis.Analyzer, interval time.Duration, done <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			fmt.Println(analyzer.Progress())
+		}
+	}
+}
+
+// exportSQL 读取已写入的用户记录并导出 stat_summary SQL
+func exportSQL(chartExporter *export.ChartExporter, userOutputDir string, cfg *config.Config) error {
+	users, err := export.ReadRecords(userOutputDir)
+	if err != nil {
+		return err
+	}
+
+	options := export.SQLOptions{
+		Dialect: cfg.SQLDialect,
+		Upsert:  cfg.SQLUpsert,
+	}
+	if cfg.SQLSourceAccount {
+		options.SourceAccount = cfg.UID
+	}
+	return chartExporter.ExportSQL(users, options)
 }
 
 // printResults 打印分析结果
-func printResults(analyzer *services.AnalyzerService) {
+func printResults(result *analysis.Result) {
 	fmt.Println("\n========================== 最终统计结果：未知机型 ============================")
-	unknownStats := analyzer.GetUnknownBrandStats()
+	unknownStats := result.Unknown
 	for _, stat := range unknownStats {
 		fmt.Printf("PhoneType: %s, Num: %d\n", stat.PhoneType, stat.Count)
 	}
 
 	fmt.Println("\n========================== 最终统计结果：已知机型 ============================")
-	knownStats := analyzer.GetKnownBrandStats()
+	knownStats := result.Known
 	for _, stat := range knownStats {
 		fmt.Printf("PhoneType: %s, Num: %d\n", stat.PhoneType, stat.Count)
 	}
@@ -103,17 +335,60 @@ func printResults(analyzer *services.AnalyzerService) {
 		totalKnown += stat.Count
 	}
 	fmt.Printf("\n已知品牌总用户数: %d\n", totalKnown)
+	if len(unknownStats) > 0 {
+		fmt.Println("使用 triage 子命令可汇总多次运行的未知来源并生成映射建议")
+	}
 }
 
-// setupGracefulShutdown 设置优雅退出
-func setupGracefulShutdown(analyzerService *services.AnalyzerService) {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+// printCookieHealth 输出各 Cookie 的请求数、失败次数与当前状态
+func printCookieHealth(cookies []analysis.CookieHealth) {
+	if len(cookies) == 0 {
+		return
+	}
+	fmt.Println("\n========================== Cookie 状态 ============================")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Cookie\t请求\t频率限制\t认证失败\t其他失败\t状态")
+	for _, cookie := range cookies {
+		state := cookie.State
+		if !cookie.CooldownUntil.IsZero() {
+			state += "（至 " + cookie.CooldownUntil.Format("15:04:05") + "）"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n",
+			cookie.Label, cookie.Requests, cookie.RateLimits, cookie.AuthErrors, cookie.Failures, state)
+	}
+	tw.Flush()
+}
 
-	go func() {
-		<-c
-		fmt.Println("\n\n收到退出信号，正在优雅退出...")
-		convertDataToChart(analyzerService)
-		os.Exit(0)
-	}()
+// printProxyHealth 输出各代理的请求数、失败次数与当前状态
+func printProxyHealth(proxies []analysis.ProxyHealth) {
+	if len(proxies) == 0 {
+		return
+	}
+	fmt.Println("\n=========================== 代理状态 ==============================")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "代理\t请求\t失败\t状态")
+	for _, proxy := range proxies {
+		state := "可用"
+		if !proxy.Healthy {
+			state = "退避中（至 " + proxy.BackoffUntil.Format("15:04:05") + "）"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", proxy.Label, proxy.Requests, proxy.Failures, state)
+	}
+	tw.Flush()
+}
+
+// printSchemaDrift 输出各接口响应结构异常的次数，没有异常时不输出
+func printSchemaDrift(drift map[string]int) {
+	if len(drift) == 0 {
+		return
+	}
+	fmt.Println("\n警告: 以下接口的响应结构与预期不符，品牌或 IP 属地可能不准确，异常响应保存在输出目录的 schema_drift_*.json:")
+	endpoints := make([]string, 0, len(drift))
+	for endpoint := range drift {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		fmt.Printf("  %s: %d 次\n", endpoint, drift[endpoint])
+	}
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"comment_phone_analyse/config"
+	"comment_phone_analyse/internal/utils"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_ExitCodes(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"help", []string{"help"}, utils.ExitOK},
+		{"unknown subcommand", []string{"nope"}, utils.ExitUsage},
+		{"report without dirs", []string{"report"}, utils.ExitUsage},
+		{"compare with one run", []string{"compare", missing}, utils.ExitUsage},
+		{"report missing dir", []string{"report", missing}, utils.ExitNotFound},
+		{"compare missing dirs", []string{"compare", missing, missing}, utils.ExitNotFound},
+		{"reclassify missing dir", []string{"reclassify", missing}, utils.ExitNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(tt.args); got != tt.want {
+				t.Errorf("run(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigFlags_OverrideConfigFile(t *testing.T) {
+	t.Setenv(config.CookieEnv, "")
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"uid":"1","cookie":"SUB=a","limit":10,"interval":3,"proxies":["http://file:1"]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addConfigFlags(flags)
+	err := flags.Parse([]string{"--config", path, "--uid", "2", "--interval", "0", "--proxy", "http://a:1, socks5://b:2", "--verbose"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := f.load()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+
+	// 显式给出的参数覆盖配置文件，即使是零值；未给出的保留配置文件中的值
+	if cfg.UID != "2" || cfg.Interval != 0 || cfg.Limit != 10 || cfg.LogLevel != "debug" {
+		t.Errorf("config = uid %s, interval %d, limit %d, log level %q", cfg.UID, cfg.Interval, cfg.Limit, cfg.LogLevel)
+	}
+	if got := strings.Join(cfg.Proxies, "|"); got != "http://a:1|socks5://b:2" {
+		t.Errorf("proxies = %s", got)
+	}
+}
+
+func TestConfigFlags_DebugAlias(t *testing.T) {
+	t.Setenv(config.CookieEnv, "")
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"uid":"1","cookie":"SUB=a"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addConfigFlags(flags)
+	if err := flags.Parse([]string{"--config", path, "--debug"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := f.load()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("log level = %q, want debug", cfg.LogLevel)
+	}
+}
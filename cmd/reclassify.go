@@ -0,0 +1,94 @@
+package main
+
+import (
+	"comment_phone_analyse/export"
+	"comment_phone_analyse/internal/legacy"
+	"comment_phone_analyse/internal/models"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// runReclassify 用当前品牌映射重新分类已完成的输出目录，离线重新生成统计、摘要与图表
+func runReclassify(args []string) error {
+	flags := newFlagSet("reclassify", "[-mapping 映射文件] 输出目录...（如 ./output/2397417584）")
+	mappingFile := flags.String("mapping", "", "追加的品牌映射文件")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	mapping, err := models.LoadPhoneMapping(*mappingFile)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	failed := 0
+	for _, dir := range flags.Args() {
+		if err := reclassifyDir(dir, mapping); err != nil {
+			slog.Error("重新分类失败", "dir", dir, "err", err)
+			lastErr = err
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个目录重新分类失败: %w", failed, lastErr)
+	}
+	return nil
+}
+
+// reclassifyDir 重新分类一个输出目录并覆盖其中的记录文件、摘要与图表，旧版只有 stats.txt 的目录同样适用
+func reclassifyDir(dir string, mapping models.PhoneBrandMapping) error {
+	users, err := legacy.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	changed := 0
+	stats := models.NewPhoneStatistics()
+	for i := range users {
+		// 早期记录没有来源原文，未识别的品牌本身就是来源原文
+		if users[i].Source == "" {
+			users[i].Source = users[i].PhoneType
+		}
+		if brand := models.ClassifySource(mapping, users[i].Source); brand != users[i].PhoneType {
+			users[i].PhoneType = brand
+			changed++
+		}
+		stats.Add(&users[i])
+	}
+	fmt.Printf("%s: %d 个用户，%d 个品牌发生变化\n", dir, len(users), changed)
+
+	written, err := export.WriteRecords(dir, users)
+	if err != nil {
+		return err
+	}
+	// 旧版目录保留 stats.txt，重新分类的结果写入新的 stats.csv
+	if len(written) == 0 {
+		if err := writeCSVRecords(filepath.Join(dir, export.CSVRecordsFile), users); err != nil {
+			return err
+		}
+	}
+
+	run := readRunInfo(dir, stats)
+	exportCharts(os.Stdout, export.NewChartExporter(run.UID, dir), stats, run)
+	return nil
+}
+
+// writeCSVRecords 将用户记录写入新的 CSV 文件
+func writeCSVRecords(filename string, users []models.UserInfo) error {
+	writer, err := export.NewCSVRecordWriter(filename)
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		if err := writer.Write(&users[i]); err != nil {
+			writer.Close()
+			return err
+		}
+	}
+	return writer.Close()
+}
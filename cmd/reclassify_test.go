@@ -0,0 +1,35 @@
+package main
+
+import (
+	"comment_phone_analyse/export"
+	"comment_phone_analyse/internal/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReclassifyDir_LegacyStatsTxt(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "2397417584")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := "5937031906:微博会员中心\n1828620705:苹果\n1234567890:iPhone 15 Pro\n"
+	if err := os.WriteFile(filepath.Join(dir, "stats.txt"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reclassifyDir(dir, models.DefaultPhoneMapping()); err != nil {
+		t.Fatalf("reclassifyDir() error = %v", err)
+	}
+
+	users, err := export.ReadRecords(dir)
+	if err != nil {
+		t.Fatalf("stats.csv not written: %v", err)
+	}
+	if len(users) != 3 || users[2].PhoneType != "苹果" || users[2].Source != "iPhone 15 Pro" {
+		t.Errorf("records = %+v", users)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stats.txt")); err != nil {
+		t.Errorf("stats.txt should be kept: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"comment_phone_analyse/export"
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/storage"
+	"comment_phone_analyse/internal/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+)
+
+// runReport 根据已完成的输出目录或数据库中的运行重新生成摘要与图表，不发起网络请求
+func runReport(args []string) error {
+	flags := newFlagSet("report", "[-db 数据库 (-run 运行ID | -uid 用户ID)] [-out 目录] [输出目录...]")
+	dbPath := flags.String("db", "", "从 SQLite 数据库读取运行")
+	runID := flags.Int64("run", 0, "数据库中的运行ID")
+	uid := flags.String("uid", "", "目标用户ID：配合 -db 时取最近一次运行，否则读取 ./output/<uid>")
+	outDir := flags.String("out", "", "配合 -db 时图表的输出目录，默认 ./output/<uid>")
+	flags.Parse(args)
+
+	if *dbPath != "" {
+		return reportFromStore(*dbPath, *runID, *uid, *outDir)
+	}
+
+	dirs := flags.Args()
+	if len(dirs) == 0 && *uid != "" {
+		dirs = []string{filepath.Join("output", *uid)}
+	}
+	if len(dirs) == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	for _, dir := range dirs {
+		users, err := export.ReadRecords(dir)
+		if err != nil {
+			return err
+		}
+		stats := models.NewPhoneStatistics()
+		for i := range users {
+			stats.Add(&users[i])
+		}
+		run := readRunInfo(dir, stats)
+		exportCharts(os.Stdout, export.NewChartExporter(run.UID, dir), stats, run)
+	}
+	return nil
+}
+
+// reportFromStore 从数据库读取运行并导出图表
+func reportFromStore(dbPath string, runID int64, uid, outDir string) error {
+	store, err := storage.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var run *storage.Run
+	switch {
+	case runID > 0:
+		run, err = store.GetRun(runID)
+	case uid != "":
+		run, err = store.LatestRun(uid)
+	default:
+		return fmt.Errorf("%w: 使用 -db 时需要指定 -run 或 -uid", errUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	stats, err := store.RunStatistics(run.ID)
+	if err != nil {
+		return err
+	}
+	if outDir == "" {
+		outDir = filepath.Join("output", run.Info.UID)
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return utils.NewExportError("创建输出目录失败", err)
+	}
+
+	exportCharts(os.Stdout, export.NewChartExporter(run.Info.UID, outDir), stats, run.Info)
+	return nil
+}
+
+// readRunInfo 读取输出目录的运行信息，旧的输出目录没有 run.json 时只给出目标用户与样本量
+func readRunInfo(dir string, stats *models.PhoneStatistics) models.RunInfo {
+	run, err := export.ReadRunInfo(dir)
+	if err != nil {
+		run = models.RunInfo{UID: filepath.Base(filepath.Clean(dir))}
+	}
+	run.SampleSize = stats.UserCount
+	return run
+}
+
+// runCompare 对比两次运行的品牌分布
+func runCompare(args []string) error {
+	flags := newFlagSet("compare", "[-db 数据库] 运行A 运行B\n\n运行可以是输出目录，或配合 -db 时的运行ID")
+	dbPath := flags.String("db", "", "从 SQLite 数据库读取以数字给出的运行")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errUsage
+	}
+
+	var store *storage.Store
+	if *dbPath != "" {
+		var err error
+		if store, err = storage.Open(*dbPath); err != nil {
+			return err
+		}
+		defer store.Close()
+	}
+
+	before, err := loadComparand(store, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := loadComparand(store, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("A: %s（%d 个用户）\nB: %s（%d 个用户）\n", before.label, before.stats.UserCount, after.label, after.stats.UserCount)
+	if len(before.users) > 0 && len(after.users) > 0 {
+		common, switched := overlap(before.users, after.users)
+		fmt.Printf("两次都出现的用户: %d，其中品牌变化: %d\n", common, switched)
+	}
+	fmt.Println()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "品牌\tA 人数\tA 占比\tB 人数\tB 占比\t变化\t")
+	for _, delta := range models.CompareBrands(before.stats, after.stats) {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%d\t%.1f%%\t%+.1f\t\n",
+			delta.Brand, delta.Before, delta.BeforeShare, delta.After, delta.AfterShare, delta.Change())
+	}
+	return tw.Flush()
+}
+
+// comparand 参与对比的一次运行
+type comparand struct {
+	label string
+	users []models.UserInfo // 只有品牌汇总的运行为空
+	stats *models.PhoneStatistics
+}
+
+// loadComparand 读取输出目录或数据库中的运行
+func loadComparand(store *storage.Store, arg string) (*comparand, error) {
+	if runID, err := strconv.ParseInt(arg, 10, 64); err == nil && store != nil {
+		run, err := store.GetRun(runID)
+		if err != nil {
+			return nil, err
+		}
+		users, err := store.RunUsers(runID)
+		if err != nil {
+			return nil, err
+		}
+		stats, err := store.RunStatistics(runID)
+		if err != nil {
+			return nil, err
+		}
+		label := fmt.Sprintf("运行 %d（%s，%s）", run.ID, run.Info.UID, run.Info.StartedAt.Format("2006-01-02 15:04"))
+		return &comparand{label: label, users: users, stats: stats}, nil
+	}
+
+	users, err := export.ReadRecords(arg)
+	if err != nil {
+		return nil, err
+	}
+	stats := models.NewPhoneStatistics()
+	for i := range users {
+		stats.Add(&users[i])
+	}
+	return &comparand{label: arg, users: users, stats: stats}, nil
+}
+
+// overlap 统计两次运行共同出现的用户数及其中品牌发生变化的人数
+func overlap(before, after []models.UserInfo) (int, int) {
+	brands := make(map[string]string, len(before))
+	for _, user := range before {
+		brands[user.Id] = user.PhoneType
+	}
+
+	common, switched := 0, 0
+	seen := make(map[string]bool, len(after))
+	for _, user := range after {
+		brand, ok := brands[user.Id]
+		if !ok || seen[user.Id] {
+			continue
+		}
+		seen[user.Id] = true
+		common++
+		if brand != user.PhoneType {
+			switched++
+		}
+	}
+	return common, switched
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"comment_phone_analyse/config"
+	"comment_phone_analyse/export"
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/schedule"
+	"comment_phone_analyse/internal/storage"
+	"comment_phone_analyse/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// scheduledTarget 一个定时分析目标及其下次运行时间
+type scheduledTarget struct {
+	entry config.Schedule
+	cron  *schedule.Schedule
+	next  time.Time
+}
+
+// runSchedule 按配置中的 schedules 定时执行分析，每次结果保存在 <输出目录>/history/<时间>/<UID>，并更新趋势图
+func runSchedule(args []string) error {
+	flags := newFlagSet("schedule", "[-now] [配置参数]")
+	now := flags.Bool("now", false, "启动时立即对全部目标执行一次")
+	configFlags := addConfigFlags(flags)
+	flags.Parse(args)
+
+	cfg, err := configFlags.load()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Schedules) == 0 {
+		return utils.NewConfigError("配置中没有 schedules，无法定时分析", nil)
+	}
+	cfg.Print()
+
+	targets := make([]*scheduledTarget, len(cfg.Schedules))
+	for i, entry := range cfg.Schedules {
+		cron, err := schedule.Parse(entry.Cron)
+		if err != nil {
+			return err
+		}
+		targets[i] = &scheduledTarget{entry: entry, cron: cron, next: cron.Next(time.Now())}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *now {
+		for _, target := range targets {
+			runScheduled(ctx, cfg, target.entry)
+		}
+	}
+
+	for ctx.Err() == nil {
+		// 同一时刻触发的目标依次执行，避免同一 Cookie 并发请求
+		next := targets[0].next
+		for _, target := range targets[1:] {
+			if target.next.Before(next) {
+				next = target.next
+			}
+		}
+		for _, target := range targets {
+			if target.next.Equal(next) {
+				fmt.Printf("下次运行: %s 用户 %s（%s）\n", next.Format("2006-01-02 15:04"), target.entry.Target(cfg), target.cron)
+			}
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		for _, target := range targets {
+			if target.next.After(next) {
+				continue
+			}
+			runScheduled(ctx, cfg, target.entry)
+			// 运行耗时超过周期时跳过错过的触发时间
+			target.next = target.cron.Next(time.Now())
+		}
+	}
+	return nil
+}
+
+// runScheduled 执行一次定时分析并更新目标用户的趋势图，失败只记录日志，不影响之后的运行
+func runScheduled(ctx context.Context, base *config.Config, entry config.Schedule) {
+	started := time.Now()
+	cfg, err := base.ForSchedule(entry, export.HistoryRunDir(base.OutputDir, started))
+	if err != nil {
+		slog.Error("定时分析配置无效", "target", entry.Target(base), "err", err)
+		return
+	}
+
+	fmt.Printf("\n[%s] 开始分析用户 %s\n", started.Format("2006-01-02 15:04:05"), cfg.UID)
+	result, err := runJob(ctx, cfg)
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Println("收到退出信号，已保存已处理的部分")
+	case err != nil:
+		slog.Error("定时分析失败", "uid", cfg.UID, "err", err)
+		return
+	default:
+		fmt.Printf("用户 %s 分析完成（%d 个用户）: %s\n", cfg.UID, result.Statistics.UserCount, result.OutputDir)
+	}
+	printCookieHealth(result.Cookies)
+	printProxyHealth(result.Proxies)
+	printSchemaDrift(result.Drift)
+
+	if err := exportHistoryTrend(base.OutputDir, cfg.UID); err != nil {
+		slog.Error("更新趋势图失败", "uid", cfg.UID, "err", err)
+	}
+}
+
+// exportHistoryTrend 根据 history 下的运行生成 <输出目录>/<UID>/trend.html 与 trend.txt
+func exportHistoryTrend(outputDir, uid string) error {
+	points, err := export.ReadHistory(outputDir, uid)
+	if err != nil {
+		return err
+	}
+	return exportTrend(filepath.Join(outputDir, uid), uid, points)
+}
+
+// exportTrend 将趋势图与报告写入 dir
+func exportTrend(dir, uid string, points []models.TrendPoint) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return utils.NewExportError("创建输出目录失败", err)
+	}
+	return export.NewChartExporter(uid, dir).ExportTrend(points)
+}
+
+// runTrend 根据历史运行重新生成品牌占比趋势图与报告，不发起网络请求
+func runTrend(args []string) error {
+	flags := newFlagSet("trend", "[-dir 输出目录 | -db 数据库] [-out 目录] 用户ID...")
+	dir := flags.String("dir", "./output", "读取 <dir>/history 下定时分析的结果，趋势图写入 <dir>/<UID>")
+	dbPath := flags.String("db", "", "改为读取 SQLite 数据库中的运行")
+	outDir := flags.String("out", "", "趋势图的输出目录，默认 <dir>/<UID>")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	var store *storage.Store
+	if *dbPath != "" {
+		var err error
+		if store, err = storage.Open(*dbPath); err != nil {
+			return err
+		}
+		defer store.Close()
+	}
+
+	for _, uid := range flags.Args() {
+		var points []models.TrendPoint
+		var err error
+		if store != nil {
+			points, err = store.Trend(uid)
+		} else {
+			points, err = export.ReadHistory(*dir, uid)
+		}
+		if err != nil {
+			return err
+		}
+		if len(points) == 0 {
+			return utils.NewNotFoundError(fmt.Sprintf("用户 %s 没有历史运行", uid), nil)
+		}
+
+		out := *outDir
+		if out == "" {
+			out = filepath.Join(*dir, uid)
+		}
+		fmt.Printf("用户 %s: %d 次运行\n", uid, len(points))
+		if err := exportTrend(out, uid, points); err != nil {
+			return err
+		}
+		fmt.Printf("趋势图与报告已保存到: %s\n", out)
+	}
+	return nil
+}
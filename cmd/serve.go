@@ -0,0 +1,117 @@
+package main
+
+import (
+	"comment_phone_analyse/config"
+	"comment_phone_analyse/internal/jobs"
+	"comment_phone_analyse/internal/server"
+	"comment_phone_analyse/internal/storage"
+	"comment_phone_analyse/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runServe 提供本地仪表盘：实时统计页面、JSON 接口与输出目录浏览，可在后台执行一次分析
+func runServe(args []string) error {
+	flags := newFlagSet("serve", "[-addr 地址] [-dir 输出目录] [-db 数据库] [-analyze] [-jobs 任务目录 [-workers N]] [配置参数]")
+	addr := flags.String("addr", "127.0.0.1:8080", "监听地址")
+	dir := flags.String("dir", "", "通过 /files/ 提供的输出目录，默认为配置中的 output_dir 或 ./output")
+	dbPath := flags.String("db", "", "在 /api/runs 中列出该数据库中的运行，默认为配置中的 storage_path")
+	analyzeFlag := flags.Bool("analyze", false, "在后台执行一次分析，页面实时显示统计")
+	jobsDir := flags.String("jobs", "", "启用 /api/jobs 任务接口，任务状态与输出保存在该目录")
+	workers := flags.Int("workers", 1, "同时执行的任务数")
+	configFlags := addConfigFlags(flags)
+	flags.Parse(args)
+
+	// 分析与任务都以配置文件（及命令行参数）为基础
+	var cfg *config.Config
+	if *analyzeFlag || *jobsDir != "" {
+		var err error
+		if cfg, err = configFlags.load(); err != nil {
+			return err
+		}
+		cfg.Print()
+		if *dir == "" {
+			*dir = cfg.OutputDir
+		}
+		if *dbPath == "" {
+			*dbPath = cfg.StoragePath
+		}
+	}
+	if *dir == "" {
+		*dir = "./output"
+	}
+	if _, err := os.Stat(*dir); err != nil {
+		return utils.NewNotFoundError(fmt.Sprintf("输出目录 %s 不存在", *dir), err)
+	}
+
+	var store *storage.Store
+	if *dbPath != "" {
+		var err error
+		if store, err = storage.Open(*dbPath); err != nil {
+			return err
+		}
+		defer store.Close()
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return utils.NewNetworkError(fmt.Sprintf("监听 %s 失败", *addr), err)
+	}
+
+	// 收到退出信号时取消分析并关闭服务；再次发送信号则立即退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	live := server.New(*dir, store)
+	if *jobsDir != "" {
+		manager, err := jobs.NewManager(cfg, *jobsDir, runJob)
+		if err != nil {
+			return err
+		}
+		live.SetJobs(manager)
+		manager.Start(ctx, *workers)
+		defer manager.Wait()
+	}
+	httpServer := &http.Server{
+		Handler: live.Handler(),
+		// 退出时结束 SSE 等长连接
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- httpServer.Serve(listener) }()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("实时统计: http://%s/  接口: /api/stats /api/users /api/runs /api/events  输出目录: /files/\n", listener.Addr())
+
+	if cfg != nil {
+		if err := analyze(ctx, cfg, live); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		fmt.Println("分析完成，页面保留最终结果，按 Ctrl+C 退出")
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return utils.NewNetworkError("HTTP 服务退出", err)
+	}
+}
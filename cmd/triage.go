@@ -0,0 +1,79 @@
+package main
+
+import (
+	"comment_phone_analyse/internal/legacy"
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/storage"
+	"comment_phone_analyse/internal/triage"
+	"comment_phone_analyse/internal/utils"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// runTriage 汇总多次运行中未识别的设备来源，输出报告并可生成候选映射规则
+func runTriage(args []string) error {
+	flags := newFlagSet("triage", "[-db 数据库] [-mapping 映射文件] [-rules 输出文件] [-top N] [输出目录...]\n\n未指定输出目录且未指定数据库时读取 ./output 下的全部运行")
+	dbPath := flags.String("db", "", "同时读取 SQLite 数据库中所有运行的设备记录")
+	mappingFile := flags.String("mapping", "", "追加的品牌映射文件")
+	rulesFile := flags.String("rules", "", "将带建议的来源以品牌映射文件格式写入该文件")
+	top := flags.Int("top", 50, "报告中最多显示的来源数，0 表示全部")
+	flags.Parse(args)
+
+	mapping, err := models.LoadPhoneMapping(*mappingFile)
+	if err != nil {
+		return err
+	}
+
+	dirs := flags.Args()
+	if len(dirs) == 0 && *dbPath == "" {
+		dirs, _ = filepath.Glob(filepath.Join("output", "*"))
+	}
+
+	var users []models.UserInfo
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			continue
+		}
+		records, err := legacy.ReadDir(dir)
+		if err != nil {
+			slog.Warn("跳过没有用户记录的目录", "dir", dir, "err", err)
+			continue
+		}
+		users = append(users, records...)
+	}
+	if *dbPath != "" {
+		store, err := storage.Open(*dbPath)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		observations, err := store.Observations("")
+		if err != nil {
+			return err
+		}
+		users = append(users, observations...)
+	}
+
+	entries := triage.Build(users, mapping)
+	fmt.Printf("共 %d 条记录，%d 个未识别来源\n\n", len(users), len(entries))
+	if err := triage.WriteReport(os.Stdout, entries, *top); err != nil {
+		return err
+	}
+
+	if *rulesFile != "" {
+		rules := triage.Rules(entries)
+		file, err := os.Create(*rulesFile)
+		if err != nil {
+			return utils.NewExportError("创建规则文件失败", err)
+		}
+		defer file.Close()
+		if err := triage.WriteRules(file, rules); err != nil {
+			return utils.NewExportError("写入规则文件失败", err)
+		}
+		fmt.Printf("\n%d 条候选规则已写入 %s，确认后可作为 brand_mapping_file 使用\n", len(rules), *rulesFile)
+	}
+	return nil
+}
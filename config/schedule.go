@@ -0,0 +1,78 @@
+package config
+
+import (
+	"comment_phone_analyse/internal/schedule"
+	"comment_phone_analyse/internal/utils"
+	"fmt"
+	"time"
+)
+
+// Schedule 定时分析的目标，未设置的字段沿用配置中的值
+type Schedule struct {
+	Cron           string `json:"cron"`          // 5 字段 cron 表达式（分 时 日 月 星期），或 @monthly 等
+	UID            string `json:"uid,omitempty"` // 为空时使用配置中的 uid
+	Limit          int    `json:"limit,omitempty"`
+	SampleStrategy string `json:"sample_strategy,omitempty"`
+	SamplePosts    int    `json:"sample_posts,omitempty"`
+	MinComments    int    `json:"min_comments,omitempty"`
+	PostKeyword    string `json:"post_keyword,omitempty"`
+}
+
+// Target 定时分析的目标用户
+func (s Schedule) Target(c *Config) string {
+	if s.UID != "" {
+		return s.UID
+	}
+	return c.UID
+}
+
+// ForSchedule 返回应用定时目标后的配置副本，结果写入 outputDir
+func (c *Config) ForSchedule(s Schedule, outputDir string) (*Config, error) {
+	cfg := *c
+	cfg.Schedules = nil
+	cfg.OutputDir = outputDir
+	cfg.UID = s.Target(c)
+	if s.Limit != 0 {
+		cfg.Limit = s.Limit
+	}
+	if s.SampleStrategy != "" {
+		cfg.SampleStrategy = s.SampleStrategy
+	}
+	if s.SamplePosts != 0 {
+		cfg.SamplePosts = s.SamplePosts
+	}
+	if s.MinComments != 0 {
+		cfg.MinComments = s.MinComments
+	}
+	if s.PostKeyword != "" {
+		cfg.PostKeyword = s.PostKeyword
+	}
+	// 未设置的候选池按本次的 limit 重新计算
+	if c.defaultPool {
+		cfg.SamplePool = 0
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// validateSchedules 检查定时目标的 cron 表达式与参数
+func (c *Config) validateSchedules() error {
+	for i, s := range c.Schedules {
+		cron, err := schedule.Parse(s.Cron)
+		if err != nil {
+			return err
+		}
+		if cron.Next(time.Now()).IsZero() {
+			return utils.NewConfigError(fmt.Sprintf("schedules[%d] 的 cron 表达式不会触发: %s", i, s.Cron), nil)
+		}
+		if s.Limit < 0 {
+			return utils.NewConfigError(fmt.Sprintf("schedules[%d] 的 limit 不能为负数", i), nil)
+		}
+		if _, err := c.ForSchedule(s, c.OutputDir); err != nil {
+			return utils.NewConfigError(fmt.Sprintf("schedules[%d] 无效", i), err)
+		}
+	}
+	return nil
+}
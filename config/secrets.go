@@ -0,0 +1,199 @@
+package config
+
+import (
+	"comment_phone_analyse/internal/utils"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// CookieEnv 保存 Cookie 的环境变量，设置后优先于配置文件
+const CookieEnv = "WEIBO_COOKIE"
+
+// cookieFromStdin cookie_file 取该值时从标准输入读取 Cookie
+const cookieFromStdin = "-"
+
+// stdin 读取 Cookie 的标准输入，测试时替换
+var stdin io.Reader = os.Stdin
+
+// envPattern 配置值中的 ${ENV} 占位符
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// envValue 含 ${ENV} 的字段插值前后的值，保存配置时据此还原占位符
+type envValue struct {
+	raw, expanded string
+}
+
+// expandEnv 将所有字符串字段（含切片、map 的值与 schedules 等嵌套结构）中的 ${ENV} 替换为环境变量的值，变量未设置时报错
+func (c *Config) expandEnv() error {
+	placeholders := make(map[string]envValue)
+	err := walkStrings(reflect.ValueOf(c).Elem(), "", func(path, s string) (string, error) {
+		if !envPattern.MatchString(s) {
+			return s, nil
+		}
+		expanded, err := expandString(s)
+		if err != nil {
+			return "", utils.NewConfigError(fmt.Sprintf("字段 %s 插值失败", path), err)
+		}
+		placeholders[path] = envValue{raw: s, expanded: expanded}
+		return expanded, nil
+	})
+	if err != nil {
+		return err
+	}
+	c.placeholders = placeholders
+	return nil
+}
+
+// restorePlaceholders 将插值后未被修改的字段还原为 ${ENV} 占位符，避免保存配置时写入密钥
+func (c *Config) restorePlaceholders() {
+	walkStrings(reflect.ValueOf(c).Elem(), "", func(path, s string) (string, error) {
+		if p, ok := c.placeholders[path]; ok && p.expanded == s {
+			return p.raw, nil
+		}
+		return s, nil
+	})
+}
+
+// walkStrings 用 fn 替换 v 中所有导出的字符串，path 为 JSON 字段路径，如 headers.Authorization、schedules[0].uid
+//
+// 切片与 map 先复制再修改，不影响共享底层数据的配置副本。
+func walkStrings(v reflect.Value, path string, fn func(path, s string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := fn(path, v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" {
+				name = field.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := walkStrings(v.Field(i), name, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		clone := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(clone, v)
+		for i := 0; i < clone.Len(); i++ {
+			if err := walkStrings(clone.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+		v.Set(clone)
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		clone := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			if err := walkStrings(value, path+"."+iter.Key().String(), fn); err != nil {
+				return err
+			}
+			clone.SetMapIndex(iter.Key(), value)
+		}
+		v.Set(clone)
+	}
+	return nil
+}
+
+// expandString 替换字符串中的 ${ENV}，只识别带花括号的写法，避免误伤 Cookie 中的 $
+func expandString(s string) (string, error) {
+	var missing []string
+	expanded := envPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := envPattern.FindStringSubmatch(match)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("环境变量 %s 未设置", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// resolveCookie 按 环境变量 WEIBO_COOKIE > cookie_file（"-" 表示标准输入）> cookie 字段 的顺序确定 Cookie
+//
+// 环境变量与文件中每行一个 Cookie，第一行作为 cookie，其余的与 cookies 字段一起轮换使用。
+func (c *Config) resolveCookie() error {
+	if cookie := strings.TrimSpace(os.Getenv(CookieEnv)); cookie != "" {
+		c.setCookies(cookie)
+		c.cookieSource = "环境变量 " + CookieEnv
+		return nil
+	}
+
+	switch c.CookieFile {
+	case "":
+		if c.Cookie != "" {
+			c.cookieSource = "配置文件"
+		}
+		return nil
+	case cookieFromStdin:
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return utils.NewConfigError("从标准输入读取Cookie失败", err)
+		}
+		c.setCookies(string(data))
+		c.cookieSource = "标准输入"
+	default:
+		data, err := os.ReadFile(c.CookieFile)
+		if err != nil {
+			return utils.NewConfigError(fmt.Sprintf("读取Cookie文件 %s 失败", c.CookieFile), err)
+		}
+		c.setCookies(string(data))
+		c.cookieSource = "文件 " + c.CookieFile
+	}
+	return nil
+}
+
+// setCookies 按行拆分 Cookie，忽略空行与 # 开头的注释
+func (c *Config) setCookies(data string) {
+	var cookies []string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			cookies = append(cookies, line)
+		}
+	}
+	c.Cookie = ""
+	if len(cookies) > 0 {
+		c.Cookie = cookies[0]
+		c.Cookies = append(cookies[1:], c.Cookies...)
+	}
+}
+
+// CookiePool 返回轮换使用的全部 Cookie：cookie 在前，去除空值与重复
+func (c *Config) CookiePool() []string {
+	seen := make(map[string]bool)
+	var pool []string
+	for _, cookie := range append([]string{c.Cookie}, c.Cookies...) {
+		cookie = strings.TrimSpace(cookie)
+		if cookie != "" && !seen[cookie] {
+			seen[cookie] = true
+			pool = append(pool, cookie)
+		}
+	}
+	return pool
+}
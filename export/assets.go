@@ -0,0 +1,49 @@
+package export
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//go:generate sh -c "mkdir -p assets/maps && curl -sSfL -o assets/echarts.min.js https://go-echarts.github.io/go-echarts-assets/assets/echarts.min.js && curl -sSfL -o assets/maps/china.js https://go-echarts.github.io/go-echarts-assets/assets/maps/china.js"
+
+// assetsFS 内嵌的 echarts 脚本，见 assets/README.md
+//
+//go:embed assets
+var assetsFS embed.FS
+
+// Asset 读取内嵌的 echarts 脚本，name 为相对 assets 目录的路径，如 echarts.min.js
+func Asset(name string) ([]byte, error) {
+	return assetsFS.ReadFile("assets/" + name)
+}
+
+// scriptTagPattern 匹配 go-echarts 生成的外链脚本标签
+var scriptTagPattern = regexp.MustCompile(`<script src="([^"]+)"></script>`)
+
+// inlineAssets 将页面中引用的 echarts 脚本替换为内嵌内容，返回替换后的页面和缺失的资源
+func inlineAssets(page []byte) ([]byte, []string) {
+	var missing []string
+	result := scriptTagPattern.ReplaceAllFunc(page, func(tag []byte) []byte {
+		src := string(scriptTagPattern.FindSubmatch(tag)[1])
+		index := strings.LastIndex(src, "/assets/")
+		if index < 0 {
+			missing = append(missing, src)
+			return tag
+		}
+
+		name := src[index+len("/assets/"):]
+		content, err := Asset(name)
+		if err != nil {
+			missing = append(missing, name)
+			return tag
+		}
+
+		// 防止脚本内容提前闭合标签
+		content = bytes.ReplaceAll(content, []byte("</script"), []byte(`<\/script`))
+		return []byte(fmt.Sprintf("<script>%s</script>", content))
+	})
+	return result, missing
+}
@@ -0,0 +1,206 @@
+package export
+
+import (
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/utils"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// 非已知品牌在交叉统计中合并为一行
+const otherBrand = "其他"
+
+// 交叉统计中每个品牌展示的维度取值个数
+const crossTabTopN = 3
+
+// 地区图表展示的地区个数
+const regionTopN = 15
+
+// genderOrder 性别图表中的系列顺序
+var genderOrder = []string{"男", "女", models.UnknownDimension}
+
+// groupBrands 将交叉统计中的未知品牌合并为“其他”，返回按人数降序的品牌列表和合并后的统计表
+func groupBrands(tab models.CrossTab) ([]string, models.CrossTab) {
+	grouped := make(models.CrossTab)
+	rowTotals := make(map[string]int)
+	for brand, cols := range tab {
+		row := brand
+		if !models.IsKnownBrand(brand) {
+			row = otherBrand
+		}
+		if grouped[row] == nil {
+			grouped[row] = make(map[string]int)
+		}
+		for col, count := range cols {
+			grouped[row][col] += count
+			rowTotals[row] += count
+		}
+	}
+
+	var brands []string
+	for _, stat := range models.SortedCounts(rowTotals) {
+		brands = append(brands, stat.PhoneType)
+	}
+	return brands, grouped
+}
+
+// writeBreakdown 写入性别、地区分布以及与品牌的交叉统计
+func writeBreakdown(w io.Writer, stats *models.PhoneStatistics) {
+	writeDistribution(w, "性别分布", stats.GenderCounts, stats.UserCount, 0)
+	writeDistribution(w, "IP属地分布", stats.IPLocationCounts, stats.UserCount, 0)
+	writeDistribution(w, "资料地区分布", stats.LocationCounts, stats.UserCount, 0)
+
+	writeCrossTab(w, "品牌 × 性别", stats.BrandGender, 0)
+	writeCrossTab(w, fmt.Sprintf("品牌 × IP属地（前%d）", crossTabTopN), stats.BrandIPLocation, crossTabTopN)
+	writeCrossTab(w, fmt.Sprintf("品牌 × 资料地区（前%d）", crossTabTopN), stats.BrandLocation, crossTabTopN)
+}
+
+// writeDistribution 写入单个维度的分布，topN 为 0 时写入全部
+func writeDistribution(w io.Writer, title string, counts map[string]int, total int, topN int) {
+	fmt.Fprintf(w, "\n========================== %s ===========================\n", title)
+	for i, stat := range models.SortedCounts(counts) {
+		if topN > 0 && i >= topN {
+			break
+		}
+		fmt.Fprintf(w, "%2d. %-8s: %4d (%5.1f%%)\n", i+1, stat.PhoneType, stat.Count, percent(stat.Count, total))
+	}
+}
+
+// writeCrossTab 写入品牌交叉统计，每个品牌列出人数最多的 topN 个取值（0 表示全部）
+func writeCrossTab(w io.Writer, title string, tab models.CrossTab, topN int) {
+	fmt.Fprintf(w, "\n========================== %s ===========================\n", title)
+	brands, grouped := groupBrands(tab)
+	for _, brand := range brands {
+		rowTotal := 0
+		for _, count := range grouped[brand] {
+			rowTotal += count
+		}
+
+		var parts []string
+		for i, stat := range models.SortedCounts(grouped[brand]) {
+			if topN > 0 && i >= topN {
+				break
+			}
+			parts = append(parts, fmt.Sprintf("%s %d (%.1f%%)", stat.PhoneType, stat.Count, percent(stat.Count, rowTotal)))
+		}
+		fmt.Fprintf(w, "%-12s: %s\n", brand, strings.Join(parts, ", "))
+	}
+}
+
+// ExportGenderChart 导出品牌 × 性别堆叠柱状图
+func (e *ChartExporter) ExportGenderChart(stats *models.PhoneStatistics) error {
+	if stats.UserCount == 0 {
+		return utils.NewExportError("没有数据可导出", nil)
+	}
+
+	filename := filepath.Join(e.outputDir, "gender.html")
+	return e.saveChart(e.genderChart(stats), filename)
+}
+
+// genderChart 生成品牌 × 性别堆叠柱状图
+func (e *ChartExporter) genderChart(stats *models.PhoneStatistics) *charts.Bar {
+	brands, grouped := groupBrands(stats.BrandGender)
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    fmt.Sprintf("用户 %s 评论者的品牌与性别", e.uid),
+			Subtitle: formatDistribution(stats.GenderCounts, stats.UserCount),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Right: "10%"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "手机品牌", AxisLabel: &opts.AxisLabel{Interval: "0"}}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "用户数量"}),
+		charts.WithInitializationOpts(opts.Initialization{
+			PageTitle: fmt.Sprintf("%s 品牌与性别", e.uid),
+		}),
+	)
+
+	bar.SetXAxis(brands)
+	for _, gender := range genderOrder {
+		var values []opts.BarData
+		for _, brand := range brands {
+			values = append(values, opts.BarData{Value: grouped[brand][gender]})
+		}
+		bar.AddSeries(gender, values, charts.WithBarChartOpts(opts.BarChart{Stack: "gender"}))
+	}
+	return bar
+}
+
+// ExportRegionChart 导出 IP 属地与资料地区按品牌堆叠的柱状图
+func (e *ChartExporter) ExportRegionChart(stats *models.PhoneStatistics) error {
+	if stats.UserCount == 0 {
+		return utils.NewExportError("没有数据可导出", nil)
+	}
+
+	page := components.NewPage()
+	page.SetPageTitle(fmt.Sprintf("%s 地区分布", e.uid))
+	page.AddCharts(
+		e.regionBar("IP属地", stats.IPLocationCounts, stats.BrandIPLocation),
+		e.regionBar("资料地区", stats.LocationCounts, stats.BrandLocation),
+	)
+
+	filename := filepath.Join(e.outputDir, "region.html")
+	return e.saveChart(page, filename)
+}
+
+// regionBar 生成前 regionTopN 个地区按品牌堆叠的柱状图
+func (e *ChartExporter) regionBar(dimension string, counts map[string]int, tab models.CrossTab) *charts.Bar {
+	var regions []string
+	for i, stat := range models.SortedCounts(counts) {
+		if i >= regionTopN {
+			break
+		}
+		regions = append(regions, stat.PhoneType)
+	}
+
+	brands, grouped := groupBrands(tab)
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    fmt.Sprintf("用户 %s 评论者的%s分布", e.uid, dimension),
+			Subtitle: fmt.Sprintf("前%d个地区，按品牌堆叠", regionTopN),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Type: "scroll", Top: "bottom"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: dimension, AxisLabel: &opts.AxisLabel{Interval: "0"}}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "用户数量"}),
+	)
+
+	bar.SetXAxis(regions)
+	for _, brand := range brands {
+		var values []opts.BarData
+		for _, region := range regions {
+			values = append(values, opts.BarData{Value: grouped[brand][region]})
+		}
+		bar.AddSeries(brand, values,
+			charts.WithBarChartOpts(opts.BarChart{Stack: "brand"}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: e.getColor(brand)}),
+		)
+	}
+	return bar
+}
+
+// formatDistribution 将分布格式化为一行文本，如“女 60.0% / 男 40.0%”
+func formatDistribution(counts map[string]int, total int) string {
+	var parts []string
+	for _, stat := range models.SortedCounts(counts) {
+		parts = append(parts, fmt.Sprintf("%s %.1f%%", stat.PhoneType, percent(stat.Count, total)))
+	}
+	return strings.Join(parts, " / ")
+}
+
+// percent 计算百分比，total 为 0 时返回 0
+func percent(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total) * 100
+}
@@ -5,9 +5,9 @@ import (
 	"comment_phone_analyse/internal/utils"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"time"
 
@@ -30,7 +30,8 @@ func NewChartExporter(uid, outputDir string) *ChartExporter {
 }
 
 // ExportAll 导出所有图表
-func (e *ChartExporter) ExportAll(data []models.StatisticsData) error {
+func (e *ChartExporter) ExportAll(stats *models.PhoneStatistics) error {
+	data := stats.BrandData()
 	if err := e.ExportBarChart(data); err != nil {
 		return utils.NewExportError("导出柱状图失败", err)
 	}
@@ -39,7 +40,19 @@ func (e *ChartExporter) ExportAll(data []models.StatisticsData) error {
 		return utils.NewExportError("导出饼图失败", err)
 	}
 
-	if err := e.ExportSummary(data); err != nil {
+	if err := e.ExportGenderChart(stats); err != nil {
+		return utils.NewExportError("导出性别图表失败", err)
+	}
+
+	if err := e.ExportRegionChart(stats); err != nil {
+		return utils.NewExportError("导出地区图表失败", err)
+	}
+
+	if err := e.ExportMapChart(stats); err != nil {
+		return utils.NewExportError("导出地图失败", err)
+	}
+
+	if err := e.ExportSummary(stats); err != nil {
 		return utils.NewExportError("导出摘要失败", err)
 	}
 
@@ -52,6 +65,13 @@ func (e *ChartExporter) ExportBarChart(data []models.StatisticsData) error {
 		return utils.NewExportError("没有数据可导出", nil)
 	}
 
+	// 保存文件
+	filename := filepath.Join(e.outputDir, "stats.html")
+	return e.saveChart(e.barChart(data), filename)
+}
+
+// barChart 生成品牌柱状图
+func (e *ChartExporter) barChart(data []models.StatisticsData) *charts.Bar {
 	bar := charts.NewBar()
 
 	// 准备数据
@@ -96,10 +116,7 @@ func (e *ChartExporter) ExportBarChart(data []models.StatisticsData) error {
 	)
 
 	bar.SetXAxis(xLabels).AddSeries("用户数量", yValues)
-
-	// 保存文件
-	filename := filepath.Join(e.outputDir, "stats.html")
-	return e.saveChart(bar, filename)
+	return bar
 }
 
 // ExportPieChart 导出饼图
@@ -108,6 +125,13 @@ func (e *ChartExporter) ExportPieChart(data []models.StatisticsData) error {
 		return utils.NewExportError("没有数据可导出", nil)
 	}
 
+	// 保存文件
+	filename := filepath.Join(e.outputDir, "pie.html")
+	return e.saveChart(e.pieChart(data), filename)
+}
+
+// pieChart 生成品牌饼图
+func (e *ChartExporter) pieChart(data []models.StatisticsData) *charts.Pie {
 	pie := charts.NewPie()
 
 	// 准备数据
@@ -136,14 +160,12 @@ func (e *ChartExporter) ExportPieChart(data []models.StatisticsData) error {
 	)
 
 	pie.AddSeries("手机品牌", pieData)
-
-	// 保存文件
-	filename := filepath.Join(e.outputDir, "pie.html")
-	return e.saveChart(pie, filename)
+	return pie
 }
 
 // ExportSummary 导出统计摘要
-func (e *ChartExporter) ExportSummary(data []models.StatisticsData) error {
+func (e *ChartExporter) ExportSummary(stats *models.PhoneStatistics) error {
+	data := stats.BrandData()
 	if len(data) == 0 {
 		return utils.NewExportError("没有数据可导出", nil)
 	}
@@ -168,10 +190,6 @@ func (e *ChartExporter) ExportSummary(data []models.StatisticsData) error {
 	fmt.Fprintf(file, "品牌数量: %d\n\n", len(data))
 
 	fmt.Fprintf(file, "详细统计:\n")
-	// 按数量降序排序
-	sort.Slice(data, func(i, j int) bool {
-		return data[i].Count > data[j].Count
-	})
 	for i, phone := range data {
 		percentage := float64(phone.Count) / float64(total) * 100
 		fmt.Fprintf(file, "%2d. %-12s: %4d (%5.1f%%)\n", i+1, phone.PhoneType, phone.Count, percentage)
@@ -179,21 +197,15 @@ func (e *ChartExporter) ExportSummary(data []models.StatisticsData) error {
 
 	// 添加未知机型信息
 	fmt.Fprintf(file, "\n========================== 未知机型统计 ===========================\n")
-	unknownCount := 0
-	knownBrands := map[string]bool{
-		"华为": true, "小米": true, "OPPO": true, "Vivo": true, "苹果": true,
-		"三星": true, "魅族": true, "真我": true, "红米": true, "一加": true,
-		"荣耀": true, "中兴": true, "努比亚": true, "IQOO": true, "未知Android": true,
-	}
-
 	for _, phone := range data {
-		if !knownBrands[phone.PhoneType] {
+		if !models.IsKnownBrand(phone.PhoneType) {
 			fmt.Fprintf(file, "PhoneType: %s, Num: %d\n", phone.PhoneType, phone.Count)
-			unknownCount += phone.Count
 		}
 	}
 
-	fmt.Printf("统计摘要已保存到: %s\n", filename)
+	writeBreakdown(file, stats)
+
+	slog.Debug("统计摘要已保存", "file", filename)
 	return nil
 }
 
@@ -209,7 +221,7 @@ func (e *ChartExporter) saveChart(chart interface{ Render(io.Writer) error }, fi
 		return utils.NewExportError("渲染图表失败", err)
 	}
 
-	fmt.Printf("图表已保存到: %s\n", filename)
+	slog.Debug("图表已保存", "file", filename)
 	return nil
 }
 
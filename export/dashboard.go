@@ -0,0 +1,132 @@
+package export
+
+import (
+	"bytes"
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/utils"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/components"
+)
+
+// 仪表盘中未知来源表格展示的行数
+const dashboardUnknownTopN = 20
+
+// dashboardHeader 仪表盘顶部的运行信息与未知来源表格
+var dashboardHeader = template.Must(template.New("header").Parse(`
+<style>
+  .dashboard-meta { font-family: sans-serif; max-width: 1100px; margin: 20px auto; }
+  .dashboard-meta table { border-collapse: collapse; margin: 10px 0 30px; }
+  .dashboard-meta th, .dashboard-meta td { border: 1px solid #ddd; padding: 4px 12px; text-align: left; }
+  .dashboard-meta th { background: #f5f5f5; }
+</style>
+<div class="dashboard-meta">
+  <h1>用户 {{.Run.UID}} 评论者手机品牌分析</h1>
+  <table>
+    <tr><th>目标用户</th><td>{{.Run.UID}}</td></tr>
+    <tr><th>时间范围</th><td>{{.Started}} ~ {{.Finished}}</td></tr>
+    <tr><th>运行时长</th><td>{{.Duration}}</td></tr>
+    <tr><th>样本量</th><td>{{.Run.SampleSize}} / {{.Run.Limit}}</td></tr>
+    <tr><th>采样策略</th><td>{{.Run.SampleStrategy}}</td></tr>
+  </table>
+  {{- if .Unknown}}
+  <h2>未知来源（前{{len .Unknown}}）</h2>
+  <table>
+    <tr><th>#</th><th>来源</th><th>用户数</th><th>占比</th></tr>
+    {{- range .Unknown}}
+    <tr><td>{{.Rank}}</td><td>{{.Source}}</td><td>{{.Count}}</td><td>{{printf "%.1f%%" .Share}}</td></tr>
+    {{- end}}
+  </table>
+  {{- end}}
+</div>
+`))
+
+// unknownRow 未知来源表格的一行
+type unknownRow struct {
+	Rank   int
+	Source string
+	Count  int
+	Share  float64
+}
+
+// ExportDashboard 将品牌、性别、地区图表与运行信息合并为单个离线 HTML 页面
+func (e *ChartExporter) ExportDashboard(stats *models.PhoneStatistics, run models.RunInfo) error {
+	data := stats.BrandData()
+	if len(data) == 0 {
+		return utils.NewExportError("没有数据可导出", nil)
+	}
+
+	var known []models.StatisticsData
+	var unknown []unknownRow
+	for _, stat := range data {
+		if models.IsKnownBrand(stat.PhoneType) {
+			known = append(known, stat)
+		} else if len(unknown) < dashboardUnknownTopN {
+			unknown = append(unknown, unknownRow{
+				Rank:   len(unknown) + 1,
+				Source: stat.PhoneType,
+				Count:  stat.Count,
+				Share:  percent(stat.Count, stats.UserCount),
+			})
+		}
+	}
+
+	page := components.NewPage()
+	page.SetPageTitle(fmt.Sprintf("%s 手机品牌分析", e.uid))
+	page.SetLayout(components.PageFlexLayout)
+	if len(known) > 0 {
+		page.AddCharts(e.pieChart(known), e.barChart(known))
+	}
+	page.AddCharts(e.genderChart(stats))
+	if domestic, overseas := regionDetails(stats); len(domestic) > 0 {
+		page.AddCharts(e.provinceMap(domestic))
+		if len(overseas) > 0 {
+			page.AddCharts(e.overseasBar(overseas))
+		}
+	}
+
+	var charts bytes.Buffer
+	if err := page.Render(&charts); err != nil {
+		return utils.NewExportError("渲染仪表盘失败", err)
+	}
+
+	var header bytes.Buffer
+	err := dashboardHeader.Execute(&header, map[string]any{
+		"Run":      run,
+		"Started":  formatTime(run.StartedAt),
+		"Finished": formatTime(run.FinishedAt),
+		"Duration": run.Duration().Round(time.Second).String(),
+		"Unknown":  unknown,
+	})
+	if err != nil {
+		return utils.NewExportError("渲染仪表盘运行信息失败", err)
+	}
+
+	html, missing := inlineAssets(charts.Bytes())
+	if len(missing) > 0 {
+		slog.Warn("缺少内嵌脚本，仪表盘无法离线打开，见 export/assets/README.md", "missing", strings.Join(missing, ", "))
+	}
+	html = bytes.Replace(html, []byte("<body>"), append([]byte("<body>"), header.Bytes()...), 1)
+
+	filename := filepath.Join(e.outputDir, "dashboard.html")
+	if err := os.WriteFile(filename, html, 0644); err != nil {
+		return utils.NewExportError("写入仪表盘失败", err)
+	}
+
+	slog.Debug("仪表盘已保存", "file", filename)
+	return nil
+}
+
+// formatTime 格式化时间，零值显示为“进行中”
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "进行中"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
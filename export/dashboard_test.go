@@ -0,0 +1,38 @@
+package export
+
+import (
+	"comment_phone_analyse/internal/models"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestExportDashboard_Offline(t *testing.T) {
+	stats := models.NewPhoneStatistics()
+	users := []*models.UserInfo{
+		{Id: "1", PhoneType: "苹果", Gender: "f", IPLocation: "北京"},
+		{Id: "2", PhoneType: "华为", Gender: "m", IPLocation: "广东"},
+		{Id: "3", PhoneType: "未知设备", Gender: "m", IPLocation: "美国"},
+	}
+	for _, user := range users {
+		stats.Add(user)
+	}
+
+	dir := t.TempDir()
+	started := time.Date(2025, 1, 1, 3, 0, 0, 0, time.Local)
+	run := models.RunInfo{UID: "42", StartedAt: started, FinishedAt: started.Add(time.Minute), Limit: 3, SampleSize: 3}
+	if err := NewChartExporter("42", dir).ExportDashboard(stats, run); err != nil {
+		t.Fatal(err)
+	}
+
+	html, err := os.ReadFile(filepath.Join(dir, "dashboard.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内嵌脚本缺失时页面会保留外链，需提交 export/assets 下的 echarts.min.js 与 maps/china.js
+	if tags := regexp.MustCompile(`<script[^>]+src=`).FindAll(html, -1); len(tags) > 0 {
+		t.Errorf("dashboard.html has %d external scripts, want none", len(tags))
+	}
+}
@@ -0,0 +1,173 @@
+package export
+
+import (
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/utils"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// 地图中用于计算 iPhone 占比的品牌
+const iPhoneBrand = "苹果"
+
+// jsUnsafe 内联到图表 JS 函数中时需要去掉的字符
+// go-echarts 会对函数源码做 JSON 转义，引号和反斜杠无法安全地出现在字面量中
+var jsUnsafe = strings.NewReplacer(`'`, "", `"`, "", `\`, "", "\n", " ", "\r", " ", "<", "", ">", "")
+
+// jsString 将文本转换为单引号 JS 字符串字面量
+func jsString(s string) string {
+	return "'" + jsUnsafe.Replace(s) + "'"
+}
+
+// regionDetail 单个省份或海外国家的品牌汇总
+type regionDetail struct {
+	Total       int
+	TopBrand    string
+	TopCount    int
+	IPhoneShare float64
+	brands      map[string]int
+}
+
+// regionDetails 按 IP 属地归一化后汇总国内省份与海外国家的品牌分布
+func regionDetails(stats *models.PhoneStatistics) (map[string]*regionDetail, map[string]*regionDetail) {
+	domestic := make(map[string]*regionDetail)
+	overseas := make(map[string]*regionDetail)
+
+	for brand, locations := range stats.BrandIPLocation {
+		for location, count := range locations {
+			name, kind := models.ClassifyIPLocation(location)
+			var target map[string]*regionDetail
+			switch kind {
+			case models.RegionDomestic:
+				target = domestic
+			case models.RegionOverseas:
+				target = overseas
+			default:
+				continue
+			}
+
+			detail := target[name]
+			if detail == nil {
+				detail = &regionDetail{brands: make(map[string]int)}
+				target[name] = detail
+			}
+			detail.Total += count
+			detail.brands[brand] += count
+		}
+	}
+
+	for _, group := range []map[string]*regionDetail{domestic, overseas} {
+		for _, detail := range group {
+			if top := models.SortedCounts(detail.brands); len(top) > 0 {
+				detail.TopBrand = top[0].PhoneType
+				detail.TopCount = top[0].Count
+			}
+			detail.IPhoneShare = percent(detail.brands[iPhoneBrand], detail.Total)
+		}
+	}
+
+	return domestic, overseas
+}
+
+// ExportMapChart 导出评论者 IP 属地的中国省份分布图，海外国家单独以柱状图展示
+func (e *ChartExporter) ExportMapChart(stats *models.PhoneStatistics) error {
+	domestic, overseas := regionDetails(stats)
+	if len(domestic) == 0 && len(overseas) == 0 {
+		return utils.NewExportError("没有IP属地数据可导出", nil)
+	}
+
+	page := components.NewPage()
+	page.SetPageTitle(fmt.Sprintf("%s IP属地分布", e.uid))
+	page.AddCharts(e.provinceMap(domestic))
+	if len(overseas) > 0 {
+		page.AddCharts(e.overseasBar(overseas))
+	}
+
+	filename := filepath.Join(e.outputDir, "map.html")
+	return e.saveChart(page, filename)
+}
+
+// provinceMap 生成省份用户数分级着色地图，提示框显示主要品牌与 iPhone 占比
+func (e *ChartExporter) provinceMap(domestic map[string]*regionDetail) *charts.Map {
+	var data []opts.MapData
+	maxCount := 0
+	for province, detail := range domestic {
+		data = append(data, opts.MapData{Name: province, Value: detail.Total})
+		maxCount = max(maxCount, detail.Total)
+	}
+
+	// 省份详情以 JS 对象字面量内联到提示框函数中
+	var entries []string
+	for province, detail := range domestic {
+		entries = append(entries, fmt.Sprintf("%s:{total:%d,top_brand:%s,top_count:%d,iphone_share:%.1f}",
+			jsString(province), detail.Total, jsString(detail.TopBrand), detail.TopCount, detail.IPhoneShare))
+	}
+	formatter := fmt.Sprintf(`function (params) {
+		var details = {%s};
+		var d = details[params.name];
+		if (!d) { return params.name + '<br/>用户数: 0'; }
+		return params.name + '<br/>用户数: ' + d.total +
+			'<br/>主要品牌: ' + d.top_brand + ' (' + d.top_count + ')' +
+			'<br/>iPhone占比: ' + d.iphone_share.toFixed(1) + '%%';
+	}`, strings.Join(entries, ","))
+
+	chinaMap := charts.NewMap()
+	chinaMap.RegisterMapType("china")
+	chinaMap.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    fmt.Sprintf("用户 %s 评论者的IP属地分布", e.uid),
+			Subtitle: "颜色表示用户数，悬停查看主要品牌与 iPhone 占比",
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Formatter: opts.FuncOpts(formatter)}),
+		charts.WithVisualMapOpts(opts.VisualMap{
+			Calculable: opts.Bool(true),
+			Min:        0,
+			Max:        float32(max(maxCount, 1)),
+			Text:       []string{"多", "少"},
+			InRange:    &opts.VisualMapInRange{Color: []string{"#E0F3F8", "#4575B4", "#313695"}},
+		}),
+		charts.WithInitializationOpts(opts.Initialization{Width: "900px", Height: "700px"}),
+	)
+	chinaMap.AddSeries("用户数", data)
+	return chinaMap
+}
+
+// overseasBar 生成海外国家/地区用户数柱状图
+func (e *ChartExporter) overseasBar(overseas map[string]*regionDetail) *charts.Bar {
+	counts := make(map[string]int, len(overseas))
+	for country, detail := range overseas {
+		counts[country] = detail.Total
+	}
+
+	var countries []string
+	var values []opts.BarData
+	for _, stat := range models.SortedCounts(counts) {
+		detail := overseas[stat.PhoneType]
+		countries = append(countries, stat.PhoneType)
+		values = append(values, opts.BarData{
+			Name:  fmt.Sprintf("主要品牌: %s, iPhone占比: %.1f%%", detail.TopBrand, detail.IPhoneShare),
+			Value: stat.Count,
+		})
+	}
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "海外IP属地",
+			Subtitle: "不在中国地图中展示的国家和地区",
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:      opts.Bool(true),
+			Formatter: opts.FuncOpts(`function (params) { return params.name + ': ' + params.value + '<br/>' + params.data.name; }`),
+		}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "国家/地区", AxisLabel: &opts.AxisLabel{Interval: "0"}}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "用户数量"}),
+	)
+	bar.SetXAxis(countries).AddSeries("用户数", values)
+	return bar
+}
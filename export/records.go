@@ -0,0 +1,247 @@
+package export
+
+import (
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/utils"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 用户记录文件名
+const (
+	CSVRecordsFile   = "stats.csv"
+	JSONLRecordsFile = "users.jsonl"
+	RunInfoFile      = "run.json"
+	RunLogFile       = "run.log" // 本次运行的日志
+)
+
+// csvHeader CSV 用户记录的表头
+var csvHeader = []string{"id", "nickname", "brand", "location", "ip_location", "gender", "source"}
+
+// RecordWriter 逐条写入用户记录，每条写入后立即落盘，保证中断时已处理的数据不丢失
+type RecordWriter interface {
+	Write(user *models.UserInfo) error
+	Close() error
+}
+
+// CSVRecordWriter 按 RFC 4180 写入带表头的 CSV 用户记录
+type CSVRecordWriter struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+// NewCSVRecordWriter 创建 CSV 用户记录文件并写入表头
+func NewCSVRecordWriter(filename string) (*CSVRecordWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, utils.NewExportError("创建CSV文件失败", err)
+	}
+
+	w := &CSVRecordWriter{file: file, writer: csv.NewWriter(file)}
+	if err := w.writeRow(csvHeader); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write 写入一条用户记录
+func (w *CSVRecordWriter) Write(user *models.UserInfo) error {
+	return w.writeRow([]string{user.Id, user.UserName, user.PhoneType, user.Location, user.IPLocation, user.Gender, user.Source})
+}
+
+// writeRow 写入一行并刷新到磁盘
+func (w *CSVRecordWriter) writeRow(row []string) error {
+	if err := w.writer.Write(row); err != nil {
+		return utils.NewExportError("写入CSV记录失败", err)
+	}
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return utils.NewExportError("写入CSV记录失败", err)
+	}
+	return w.file.Sync()
+}
+
+// Close 关闭文件
+func (w *CSVRecordWriter) Close() error {
+	w.writer.Flush()
+	return w.file.Close()
+}
+
+// JSONLRecordWriter 每行写入一个完整的 UserInfo JSON 对象
+type JSONLRecordWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewJSONLRecordWriter 创建 JSON Lines 用户记录文件
+func NewJSONLRecordWriter(filename string) (*JSONLRecordWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, utils.NewExportError("创建JSONL文件失败", err)
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	return &JSONLRecordWriter{file: file, encoder: encoder}, nil
+}
+
+// Write 写入一条用户记录
+func (w *JSONLRecordWriter) Write(user *models.UserInfo) error {
+	if err := w.encoder.Encode(user); err != nil {
+		return utils.NewExportError("写入JSONL记录失败", err)
+	}
+	return w.file.Sync()
+}
+
+// Close 关闭文件
+func (w *JSONLRecordWriter) Close() error {
+	return w.file.Close()
+}
+
+// WriteRecords 用给定的用户记录重写输出目录中已存在的记录文件，返回重写的文件
+func WriteRecords(dir string, users []models.UserInfo) ([]string, error) {
+	var written []string
+	for _, name := range []string{CSVRecordsFile, JSONLRecordsFile} {
+		filename := filepath.Join(dir, name)
+		if _, err := os.Stat(filename); err != nil {
+			continue
+		}
+
+		var writer RecordWriter
+		var err error
+		if name == CSVRecordsFile {
+			writer, err = NewCSVRecordWriter(filename)
+		} else {
+			writer, err = NewJSONLRecordWriter(filename)
+		}
+		if err != nil {
+			return written, err
+		}
+		for i := range users {
+			if err := writer.Write(&users[i]); err != nil {
+				writer.Close()
+				return written, err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return written, utils.NewExportError("关闭记录文件失败", err)
+		}
+		written = append(written, filename)
+	}
+	return written, nil
+}
+
+// WriteRunInfo 将运行信息写入输出目录的 run.json
+func WriteRunInfo(dir string, run models.RunInfo) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return utils.NewExportError("序列化运行信息失败", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, RunInfoFile), data, 0644); err != nil {
+		return utils.NewExportError("写入运行信息失败", err)
+	}
+	return nil
+}
+
+// ReadRunInfo 读取输出目录的 run.json
+func ReadRunInfo(dir string) (models.RunInfo, error) {
+	var run models.RunInfo
+	data, err := os.ReadFile(filepath.Join(dir, RunInfoFile))
+	if err != nil {
+		return run, readError("读取运行信息失败", err)
+	}
+	if err := json.Unmarshal(data, &run); err != nil {
+		return run, utils.NewParseError("解析运行信息失败", err)
+	}
+	return run, nil
+}
+
+// readError 读取输入文件失败的错误，文件或目录不存在时为 NotFoundError
+func readError(message string, err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return utils.NewNotFoundError(message, err)
+	}
+	return utils.NewExportError(message, err)
+}
+
+// ReadRecords 读取输出目录中的用户记录，优先使用信息更完整的 users.jsonl
+func ReadRecords(dir string) ([]models.UserInfo, error) {
+	jsonlPath := filepath.Join(dir, JSONLRecordsFile)
+	if _, err := os.Stat(jsonlPath); err == nil {
+		return ReadJSONLRecords(jsonlPath)
+	}
+	return ReadCSVRecords(filepath.Join(dir, CSVRecordsFile))
+}
+
+// ReadCSVRecords 读取带表头的 CSV 用户记录，按表头名称定位列
+func ReadCSVRecords(filename string) ([]models.UserInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, readError("打开CSV文件失败", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, utils.NewParseError("读取CSV表头失败", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var users []models.UserInfo
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, utils.NewParseError("读取CSV记录失败", err)
+		}
+		users = append(users, models.UserInfo{
+			Id:         field(row, "id"),
+			UserName:   field(row, "nickname"),
+			PhoneType:  field(row, "brand"),
+			Location:   field(row, "location"),
+			IPLocation: field(row, "ip_location"),
+			Gender:     field(row, "gender"),
+			Source:     field(row, "source"),
+		})
+	}
+	return users, nil
+}
+
+// ReadJSONLRecords 读取 JSON Lines 用户记录，跳过空行
+func ReadJSONLRecords(filename string) ([]models.UserInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, readError("打开JSONL文件失败", err)
+	}
+	defer file.Close()
+
+	var users []models.UserInfo
+	decoder := json.NewDecoder(file)
+	for {
+		var user models.UserInfo
+		if err := decoder.Decode(&user); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, utils.NewParseError("解析JSONL记录失败", err)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
@@ -0,0 +1,82 @@
+package export
+
+import (
+	"bufio"
+	"comment_phone_analyse/internal/models"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCSVRecordWriter_QuotesNicknames(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), CSVRecordsFile)
+	writer, err := NewCSVRecordWriter(filename)
+	if err != nil {
+		t.Fatalf("NewCSVRecordWriter() error = %v", err)
+	}
+
+	user := &models.UserInfo{Id: "1", UserName: `逗号,引号"换行` + "\n", PhoneType: "苹果", Location: "香港 其他", IPLocation: "广东", Gender: "f"}
+	if err := writer.Write(user); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if len(rows) != 2 || len(rows[1]) != len(csvHeader) {
+		t.Fatalf("rows = %q, want header and one record", rows)
+	}
+	if rows[0][1] != "nickname" || rows[1][1] != user.UserName {
+		t.Errorf("nickname column = %q, want %q", rows[1][1], user.UserName)
+	}
+}
+
+func TestJSONLRecordWriter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), JSONLRecordsFile)
+	writer, err := NewJSONLRecordWriter(filename)
+	if err != nil {
+		t.Fatalf("NewJSONLRecordWriter() error = %v", err)
+	}
+
+	users := []*models.UserInfo{
+		{Id: "1", UserName: "a,b", PhoneType: "<a>视频号</a>"},
+		{Id: "2", UserName: "c", PhoneType: "华为"},
+	}
+	for _, user := range users {
+		if err := writer.Write(user); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	writer.Close()
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var got []models.UserInfo
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var user models.UserInfo
+		if err := json.Unmarshal(scanner.Bytes(), &user); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, user)
+	}
+	if len(got) != 2 || got[0] != *users[0] || got[1] != *users[1] {
+		t.Errorf("records = %+v, want %+v", got, users)
+	}
+}
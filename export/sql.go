@@ -0,0 +1,251 @@
+package export
+
+import (
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/utils"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SQLDialect SQL 方言
+type SQLDialect string
+
+// 支持的 SQL 方言
+const (
+	DialectMySQL    SQLDialect = "mysql"
+	DialectPostgres SQLDialect = "postgres"
+	DialectSQLite   SQLDialect = "sqlite"
+)
+
+// 默认表名与每条 INSERT 语句包含的行数
+const (
+	defaultSQLTable     = "stat_summary"
+	defaultSQLBatchSize = 100
+)
+
+// sqlColumns stat_summary 表的数据列（不含 source_account）
+var sqlColumns = []string{"ID", "nickname", "brand", "location", "ip_location", "gender"}
+
+// SQLOptions SQL 导出选项
+type SQLOptions struct {
+	Dialect       SQLDialect
+	Table         string // 表名，默认 stat_summary
+	BatchSize     int    // 每条 INSERT 的行数，默认 100
+	Upsert        bool   // 主键冲突时更新而不是报错，用于多次导入去重
+	SourceAccount string // 非空时增加 source_account 列并作为主键的一部分
+}
+
+// SQLExporter 生成 stat_summary 建表语句与批量插入语句
+type SQLExporter struct {
+	options SQLOptions
+}
+
+// NewSQLExporter 创建 SQL 导出器
+func NewSQLExporter(options SQLOptions) (*SQLExporter, error) {
+	switch options.Dialect {
+	case DialectMySQL, DialectPostgres, DialectSQLite:
+	default:
+		return nil, utils.NewExportError(fmt.Sprintf("不支持的SQL方言: %s", options.Dialect), nil)
+	}
+
+	if options.Table == "" {
+		options.Table = defaultSQLTable
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultSQLBatchSize
+	}
+	if options.SourceAccount != "" && !utils.IsNumeric(options.SourceAccount) {
+		return nil, utils.NewExportError(fmt.Sprintf("source_account 必须是数字ID: %s", options.SourceAccount), nil)
+	}
+
+	return &SQLExporter{options: options}, nil
+}
+
+// columns 返回当前选项下的全部列
+func (e *SQLExporter) columns() []string {
+	if e.options.SourceAccount != "" {
+		return append(append([]string{}, sqlColumns...), "source_account")
+	}
+	return sqlColumns
+}
+
+// keyColumns 返回主键列
+func (e *SQLExporter) keyColumns() []string {
+	if e.options.SourceAccount != "" {
+		return []string{"ID", "source_account"}
+	}
+	return []string{"ID"}
+}
+
+// WriteSchema 写入建表语句
+func (e *SQLExporter) WriteSchema(w io.Writer) error {
+	var intType, textType, shortTextType, suffix string
+	switch e.options.Dialect {
+	case DialectMySQL:
+		intType, textType, shortTextType = "BIGINT", "VARCHAR(512)", "VARCHAR(64)"
+		suffix = " DEFAULT CHARSET=utf8mb4"
+	case DialectPostgres:
+		intType, textType, shortTextType = "BIGINT", "TEXT", "TEXT"
+	case DialectSQLite:
+		intType, textType, shortTextType = "INTEGER", "TEXT", "TEXT"
+	}
+
+	definitions := []string{
+		fmt.Sprintf("%s %s NOT NULL", e.quoteIdent("ID"), intType),
+		fmt.Sprintf("%s %s", e.quoteIdent("nickname"), shortTextType),
+		fmt.Sprintf("%s %s", e.quoteIdent("brand"), textType),
+		fmt.Sprintf("%s %s", e.quoteIdent("location"), shortTextType),
+		fmt.Sprintf("%s %s", e.quoteIdent("ip_location"), shortTextType),
+		fmt.Sprintf("%s CHAR(1)", e.quoteIdent("gender")),
+	}
+	if e.options.SourceAccount != "" {
+		definitions = append(definitions, fmt.Sprintf("%s %s NOT NULL", e.quoteIdent("source_account"), intType))
+	}
+	definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", e.quoteIdents(e.keyColumns())))
+
+	_, err := fmt.Fprintf(w, "CREATE TABLE IF NOT EXISTS %s (\n  %s\n)%s;\n",
+		e.quoteIdent(e.options.Table), strings.Join(definitions, ",\n  "), suffix)
+	return err
+}
+
+// WriteInserts 写入批量插入语句，同一主键只保留最后一条记录
+func (e *SQLExporter) WriteInserts(w io.Writer, users []models.UserInfo) error {
+	rows, err := e.rows(users)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(rows); start += e.options.BatchSize {
+		end := min(start+e.options.BatchSize, len(rows))
+		if _, err := fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES\n  %s%s;\n",
+			e.quoteIdent(e.options.Table), e.quoteIdents(e.columns()),
+			strings.Join(rows[start:end], ",\n  "), e.upsertClause()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rows 将用户转换为 VALUES 元组并按主键去重
+func (e *SQLExporter) rows(users []models.UserInfo) ([]string, error) {
+	index := make(map[string]int)
+	var rows []string
+	for _, user := range users {
+		if !utils.IsNumeric(user.Id) {
+			return nil, utils.NewExportError(fmt.Sprintf("用户ID不是数字: %q", user.Id), nil)
+		}
+
+		values := []string{
+			user.Id,
+			e.quoteString(user.UserName),
+			e.quoteString(user.PhoneType),
+			e.quoteString(user.Location),
+			e.quoteString(user.IPLocation),
+			e.quoteString(user.Gender),
+		}
+		if e.options.SourceAccount != "" {
+			values = append(values, e.options.SourceAccount)
+		}
+		row := "(" + strings.Join(values, ", ") + ")"
+
+		if i, exists := index[user.Id]; exists {
+			rows[i] = row
+			continue
+		}
+		index[user.Id] = len(rows)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// upsertClause 返回主键冲突时的更新子句
+func (e *SQLExporter) upsertClause() string {
+	if !e.options.Upsert {
+		return ""
+	}
+
+	var updates []string
+	for _, column := range sqlColumns[1:] {
+		ident := e.quoteIdent(column)
+		if e.options.Dialect == DialectMySQL {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", ident, ident))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", ident, ident))
+		}
+	}
+
+	if e.options.Dialect == DialectMySQL {
+		return "\nON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	}
+	return fmt.Sprintf("\nON CONFLICT (%s) DO UPDATE SET %s", e.quoteIdents(e.keyColumns()), strings.Join(updates, ", "))
+}
+
+// quoteIdent 按方言引用标识符
+func (e *SQLExporter) quoteIdent(name string) string {
+	if e.options.Dialect == DialectMySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteIdents 引用并以逗号连接多个标识符
+func (e *SQLExporter) quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = e.quoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// mysqlEscaper MySQL 默认模式下反斜杠是转义符，需要一并转义
+var mysqlEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `''`,
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x1a", `\Z`,
+)
+
+// quoteString 按方言转义字符串字面量
+func (e *SQLExporter) quoteString(s string) string {
+	if e.options.Dialect == DialectMySQL {
+		return "'" + mysqlEscaper.Replace(s) + "'"
+	}
+	// PostgreSQL（standard_conforming_strings）与 SQLite 只需双写单引号，文本中不允许 NUL
+	s = strings.ReplaceAll(s, "\x00", "")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// ExportSQL 导出建表语句与插入语句到 stat_summary.<方言>.sql
+func (e *ChartExporter) ExportSQL(users []models.UserInfo, options SQLOptions) error {
+	if len(users) == 0 {
+		return utils.NewExportError("没有数据可导出", nil)
+	}
+
+	exporter, err := NewSQLExporter(options)
+	if err != nil {
+		return err
+	}
+
+	filename := filepath.Join(e.outputDir, fmt.Sprintf("%s.%s.sql", exporter.options.Table, options.Dialect))
+	file, err := os.Create(filename)
+	if err != nil {
+		return utils.NewExportError("创建SQL文件失败", err)
+	}
+	defer file.Close()
+
+	if err := exporter.WriteSchema(file); err != nil {
+		return utils.NewExportError("写入建表语句失败", err)
+	}
+	if err := exporter.WriteInserts(file, users); err != nil {
+		return utils.NewExportError("写入插入语句失败", err)
+	}
+
+	slog.Debug("SQL已保存", "file", filename)
+	return nil
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"comment_phone_analyse/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestSQLExporter_QuoteString(t *testing.T) {
+	tests := []struct {
+		dialect SQLDialect
+		input   string
+		want    string
+	}{
+		{DialectMySQL, `it's`, `'it''s'`},
+		{DialectMySQL, `a\b`, `'a\\b'`},
+		{DialectMySQL, "line\nbreak", `'line\nbreak'`},
+		{DialectPostgres, `it's`, `'it''s'`},
+		{DialectPostgres, `a\b`, `'a\b'`},
+		{DialectSQLite, "nul\x00byte", `'nulbyte'`},
+	}
+
+	for _, tt := range tests {
+		exporter, err := NewSQLExporter(SQLOptions{Dialect: tt.dialect})
+		if err != nil {
+			t.Fatalf("NewSQLExporter(%s) error = %v", tt.dialect, err)
+		}
+		if got := exporter.quoteString(tt.input); got != tt.want {
+			t.Errorf("%s quoteString(%q) = %s, want %s", tt.dialect, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSQLExporter_WriteInserts(t *testing.T) {
+	users := []models.UserInfo{
+		{Id: "1", UserName: "吉祥'婉宝", PhoneType: "Vivo", Location: "其他", IPLocation: "湖北", Gender: "f"},
+		{Id: "2", UserName: "b", PhoneType: "苹果"},
+		{Id: "3", UserName: "c", PhoneType: "华为"},
+		{Id: "1", UserName: "改名", PhoneType: "Vivo"},
+	}
+
+	exporter, err := NewSQLExporter(SQLOptions{Dialect: DialectPostgres, BatchSize: 2, Upsert: true, SourceAccount: "42"})
+	if err != nil {
+		t.Fatalf("NewSQLExporter() error = %v", err)
+	}
+
+	var out strings.Builder
+	if err := exporter.WriteInserts(&out, users); err != nil {
+		t.Fatalf("WriteInserts() error = %v", err)
+	}
+	sql := out.String()
+
+	if n := strings.Count(sql, "INSERT INTO"); n != 2 {
+		t.Errorf("got %d INSERT statements, want 2 batches:\n%s", n, sql)
+	}
+	if strings.Contains(sql, "吉祥") || !strings.Contains(sql, "(1, '改名', 'Vivo', '', '', '', 42)") {
+		t.Errorf("duplicate ID should keep the last record:\n%s", sql)
+	}
+	if !strings.Contains(sql, `ON CONFLICT ("ID", "source_account") DO UPDATE SET "nickname" = excluded."nickname"`) {
+		t.Errorf("missing upsert clause:\n%s", sql)
+	}
+}
+
+func TestSQLExporter_RejectsNonNumericID(t *testing.T) {
+	exporter, _ := NewSQLExporter(SQLOptions{Dialect: DialectMySQL})
+	err := exporter.WriteInserts(&strings.Builder{}, []models.UserInfo{{Id: "1; DROP TABLE x"}})
+	if err == nil {
+		t.Fatal("WriteInserts() error = nil, want error for non-numeric ID")
+	}
+}
+
+func TestSQLExporter_WriteSchema(t *testing.T) {
+	exporter, _ := NewSQLExporter(SQLOptions{Dialect: DialectMySQL, SourceAccount: "42"})
+
+	var out strings.Builder
+	if err := exporter.WriteSchema(&out); err != nil {
+		t.Fatalf("WriteSchema() error = %v", err)
+	}
+
+	for _, want := range []string{"CREATE TABLE IF NOT EXISTS `stat_summary`", "`source_account` BIGINT NOT NULL", "PRIMARY KEY (`ID`, `source_account`)", "utf8mb4"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("schema missing %q:\n%s", want, out.String())
+		}
+	}
+}
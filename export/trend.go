@@ -0,0 +1,167 @@
+package export
+
+import (
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/utils"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// 趋势文件名
+const (
+	HistoryDir      = "history" // 定时分析的结果保存在 <输出目录>/history/<时间>/<UID>
+	TrendChartFile  = "trend.html"
+	TrendReportFile = "trend.txt"
+)
+
+// trendTopN 趋势图中展示的品牌数，其余品牌只出现在报告中
+const trendTopN = 10
+
+// trendTimeLayout 趋势图横轴的时间格式
+const trendTimeLayout = "2006-01-02 15:04"
+
+// historyTimeLayout history 下每次运行的目录名格式
+const historyTimeLayout = "20060102-150405"
+
+// HistoryRunDir 一次定时分析的输出根目录，分析器在其下创建 <UID> 目录
+func HistoryRunDir(outputDir string, t time.Time) string {
+	return filepath.Join(outputDir, HistoryDir, t.Format(historyTimeLayout))
+}
+
+// ParseHistoryTime 解析 history 下的目录名
+func ParseHistoryTime(name string) (time.Time, error) {
+	return time.ParseInLocation(historyTimeLayout, name, time.Local)
+}
+
+// ReadHistory 读取 <outputDir>/history 下目标用户的全部运行，按时间排序
+//
+// 运行时间取自 run.json，缺失时使用目录名中的时间。
+func ReadHistory(outputDir, uid string) ([]models.TrendPoint, error) {
+	dirs, err := filepath.Glob(filepath.Join(outputDir, HistoryDir, "*", uid))
+	if err != nil {
+		return nil, utils.NewParseError("查找历史运行失败", err)
+	}
+
+	var points []models.TrendPoint
+	for _, dir := range dirs {
+		users, err := ReadRecords(dir)
+		if err != nil || len(users) == 0 {
+			continue
+		}
+		stats := models.NewPhoneStatistics()
+		for i := range users {
+			stats.Add(&users[i])
+		}
+
+		point := models.TrendPoint{Stats: stats}
+		if run, err := ReadRunInfo(dir); err == nil {
+			point.Time = run.StartedAt
+		}
+		if point.Time.IsZero() {
+			point.Time, _ = ParseHistoryTime(filepath.Base(filepath.Dir(dir)))
+		}
+		points = append(points, point)
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	return points, nil
+}
+
+// ExportTrend 导出各品牌占比随时间变化的折线图与趋势报告
+func (e *ChartExporter) ExportTrend(points []models.TrendPoint) error {
+	if len(points) == 0 {
+		return utils.NewExportError("没有历史运行可导出趋势", nil)
+	}
+	trends := models.Trend(points)
+
+	if err := e.saveChart(e.trendChart(points, trends), filepath.Join(e.outputDir, TrendChartFile)); err != nil {
+		return err
+	}
+	return e.exportTrendReport(points, trends)
+}
+
+// trendChart 生成前 trendTopN 个品牌的占比折线图
+func (e *ChartExporter) trendChart(points []models.TrendPoint, trends []models.BrandTrend) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    fmt.Sprintf("用户 %s 的手机品牌占比趋势", e.uid),
+			Subtitle: fmt.Sprintf("%d 次运行，前 %d 个品牌", len(points), min(trendTopN, len(trends))),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Type: "scroll", Top: "bottom"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "运行时间"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "占比 (%)"}),
+		charts.WithGridOpts(opts.Grid{Left: "10%", Right: "10%", Bottom: "15%", Top: "15%"}),
+		charts.WithInitializationOpts(opts.Initialization{
+			PageTitle: fmt.Sprintf("%s 手机品牌趋势", e.uid),
+		}),
+	)
+
+	labels := make([]string, len(points))
+	for i, point := range points {
+		labels[i] = point.Time.Format(trendTimeLayout)
+	}
+	line.SetXAxis(labels)
+
+	for _, trend := range trends[:min(trendTopN, len(trends))] {
+		data := make([]opts.LineData, len(trend.Shares))
+		for i, share := range trend.Shares {
+			data[i] = opts.LineData{Value: math.Round(share*10) / 10}
+		}
+		line.AddSeries(trend.Brand, data, charts.WithItemStyleOpts(opts.ItemStyle{Color: e.getColor(trend.Brand)}))
+	}
+	return line
+}
+
+// exportTrendReport 写入各次运行的样本量与全部品牌的占比
+func (e *ChartExporter) exportTrendReport(points []models.TrendPoint, trends []models.BrandTrend) error {
+	filename := filepath.Join(e.outputDir, TrendReportFile)
+	file, err := os.Create(filename)
+	if err != nil {
+		return utils.NewExportError("创建趋势报告失败", err)
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "=== 用户 %s 手机品牌占比趋势 ===\n\n", e.uid)
+	fmt.Fprintf(file, "统计时间: %s\n", getNowTime())
+	fmt.Fprintf(file, "运行次数: %d（%s 至 %s）\n\n", len(points),
+		points[0].Time.Format(trendTimeLayout), points[len(points)-1].Time.Format(trendTimeLayout))
+
+	tw := tabwriter.NewWriter(file, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := []string{"品牌"}
+	users := []string{"用户数"}
+	for _, point := range points {
+		header = append(header, point.Time.Format("01-02 15:04"))
+		users = append(users, fmt.Sprint(point.Stats.UserCount))
+	}
+	header = append(header, "变化")
+	users = append(users, "")
+	fmt.Fprintln(tw, strings.Join(header, "\t")+"\t")
+	fmt.Fprintln(tw, strings.Join(users, "\t")+"\t")
+
+	for _, trend := range trends {
+		row := []string{trend.Brand}
+		for _, share := range trend.Shares {
+			row = append(row, fmt.Sprintf("%.1f%%", share))
+		}
+		// 首末两次运行的占比变化（百分点）
+		row = append(row, fmt.Sprintf("%+.1f", trend.Shares[len(trend.Shares)-1]-trend.Shares[0]))
+		fmt.Fprintln(tw, strings.Join(row, "\t")+"\t")
+	}
+	if err := tw.Flush(); err != nil {
+		return utils.NewExportError("写入趋势报告失败", err)
+	}
+
+	slog.Debug("趋势报告已保存", "file", filename)
+	return nil
+}
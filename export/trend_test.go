@@ -0,0 +1,71 @@
+package export
+
+import (
+	"comment_phone_analyse/internal/models"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadHistory_ExportTrend(t *testing.T) {
+	output := t.TempDir()
+	runs := []struct {
+		at     time.Time
+		brands []string
+	}{
+		{time.Date(2025, 2, 1, 3, 0, 0, 0, time.Local), []string{"苹果", "华为", "华为", "华为"}},
+		{time.Date(2025, 1, 1, 3, 0, 0, 0, time.Local), []string{"苹果", "苹果", "苹果", "华为"}},
+	}
+	for i, run := range runs {
+		dir := filepath.Join(HistoryRunDir(output, run.at), "42")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		writer, err := NewCSVRecordWriter(filepath.Join(dir, CSVRecordsFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, brand := range run.brands {
+			writer.Write(&models.UserInfo{Id: fmt.Sprint(j), PhoneType: brand})
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// 第二次运行没有 run.json，时间取自目录名
+		if i == 0 {
+			if err := WriteRunInfo(dir, models.RunInfo{UID: "42", StartedAt: run.at}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	points, err := ReadHistory(output, "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || !points[0].Time.Equal(runs[1].at) || points[0].Stats.BrandCounts["苹果"] != 3 {
+		t.Fatalf("ReadHistory() = %+v", points)
+	}
+	if other, _ := ReadHistory(output, "43"); len(other) != 0 {
+		t.Errorf("ReadHistory(other uid) = %d points, want 0", len(other))
+	}
+
+	dir := filepath.Join(output, "42")
+	os.MkdirAll(dir, 0755)
+	if err := NewChartExporter("42", dir).ExportTrend(points); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, TrendChartFile)); err != nil {
+		t.Error(err)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, TrendReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "75.0%") || !strings.Contains(string(report), "+50.0") {
+		t.Errorf("trend report:\n%s", report)
+	}
+}
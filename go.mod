@@ -2,4 +2,21 @@ module comment_phone_analyse
 
 go 1.23
 
-require github.com/go-echarts/go-echarts/v2 v2.5.0
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/go-echarts/go-echarts/v2 v2.5.0
+	github.com/klauspost/compress v1.17.11
+	modernc.org/sqlite v1.34.5
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
+)
@@ -1,90 +1,391 @@
 package client
 
 import (
-	"compress/gzip"
+	"comment_phone_analyse/internal/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
-// Client 微博API客户端
+// 默认的停用时长
+const (
+	DefaultCooldown     = 10 * time.Minute // 触发频率限制后
+	DefaultAuthCooldown = 30 * time.Minute // 认证失败后
+)
+
+// budgetWindow 请求预算的统计周期
+const budgetWindow = time.Hour
+
+// DefaultHeaders 与微博网页端一致的默认请求头，可通过 Options.Headers 覆盖
+var DefaultHeaders = map[string]string{
+	"Accept":             "application/json, text/plain, */*",
+	"Accept-Encoding":    "gzip, deflate, br, zstd",
+	"Accept-Language":    "zh-CN,zh;q=0.9",
+	"Client-Version":     "v2.47.130",
+	"Priority":           "u=1, i",
+	"Referer":            "https://weibo.com",
+	"Sec-CH-UA":          `"Chromium";v="142", "Google Chrome";v="142", "Not_A Brand";v="99"`,
+	"Sec-CH-UA-Mobile":   "?0",
+	"Sec-CH-UA-Platform": `"Linux"`,
+	"Sec-Fetch-Dest":     "empty",
+	"Sec-Fetch-Mode":     "cors",
+	"Sec-Fetch-Site":     "same-origin",
+	"Server-Version":     "v2025.10.31.1",
+	"User-Agent":         "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/142.0.0.0 Safari/537.36",
+	"X-Requested-With":   "XMLHttpRequest",
+}
+
+var (
+	// ErrAuth Cookie 无效或已过期：401/403、被重定向到登录页或接口返回 ok=-100
+	ErrAuth = errors.New("Cookie 认证失败")
+	// ErrRateLimited 请求过于频繁：414/418/429
+	ErrRateLimited = errors.New("请求过于频繁")
+)
+
+// Options 客户端选项
+type Options struct {
+	Budget       int           // 每个 Cookie 每小时最多发起的请求数，0 表示不限
+	Cooldown     time.Duration // 触发频率限制后停用该 Cookie 的时长，0 使用 DefaultCooldown
+	AuthCooldown time.Duration // 认证失败后停用该 Cookie 的时长，0 使用 DefaultAuthCooldown
+
+	// Headers 覆盖 DefaultHeaders 中的同名请求头，值为空时不发送该请求头
+	Headers map[string]string
+
+	Proxies   []string // HTTP 或 SOCKS5 代理，为空时直连（或使用环境变量中的代理）
+	ProxyMode string   // 代理分配方式，ProxyRoundRobin（默认）或 ProxySticky
+
+	Logger *slog.Logger // 为 nil 时使用 slog.Default()；每个请求在 debug 级别记录
+}
+
+// Cookie 状态
+const (
+	CookieActive      = "active"       // 可用
+	CookieRateLimited = "rate_limited" // 触发频率限制，冷却中
+	CookieAuthFailed  = "auth_failed"  // 认证失败，冷却中
+	CookieExhausted   = "exhausted"    // 本小时的请求预算已用完
+)
+
+// CookieHealth 一个 Cookie 的请求统计与当前状态
+type CookieHealth struct {
+	Label         string    `json:"label"` // 序号与 Cookie 的短哈希，不含 Cookie 内容
+	State         string    `json:"state"`
+	Requests      int       `json:"requests"`
+	Failures      int       `json:"failures"` // 网络错误等其他失败
+	RateLimits    int       `json:"rate_limits"`
+	AuthErrors    int       `json:"auth_errors"`
+	CooldownUntil time.Time `json:"cooldown_until,omitempty"`
+}
+
+// cookieState 池中的一个 Cookie
+type cookieState struct {
+	index  int
+	jar    *cookieJar
+	health CookieHealth
+
+	windowStart time.Time // 当前预算周期的开始时间
+	windowCount int       // 当前周期已发起的请求数
+}
+
+// Client 微博API客户端，请求在多个 Cookie 间轮换
 type Client struct {
 	httpClient *http.Client
-	cookie     string
+	options    Options
+	headers    http.Header
+	logger     *slog.Logger
+
+	mutex     sync.Mutex
+	cookies   []*cookieState
+	next      int // 下一个尝试的 Cookie
+	proxies   []*proxyState
+	nextProxy int // 轮换分配时下一个尝试的代理
 }
 
-// NewClient 创建新的微博客户端
+// NewClient 创建只使用一个 Cookie 的客户端
 func NewClient(cookie string) *Client {
-	return &Client{
+	return NewPool([]string{cookie}, Options{})
+}
+
+// NewPool 创建在多个 Cookie 间轮换的客户端
+//
+// 每个 Cookie 是独立的会话：请求头 X-XSRF-TOKEN 取自其中的 XSRF-TOKEN，响应中的 Set-Cookie 会更新该会话。
+func NewPool(cookies []string, options Options) *Client {
+	if options.Cooldown <= 0 {
+		options.Cooldown = DefaultCooldown
+	}
+	if options.AuthCooldown <= 0 {
+		options.AuthCooldown = DefaultAuthCooldown
+	}
+
+	c := &Client{
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: newTransport(),
 		},
-		cookie: cookie,
+		options: options,
+		headers: make(http.Header),
+		logger:  options.Logger,
+	}
+	if c.logger == nil {
+		c.logger = slog.Default()
+	}
+	for name, value := range DefaultHeaders {
+		c.headers.Set(name, value)
+	}
+	for name, value := range options.Headers {
+		if value == "" {
+			c.headers.Del(name)
+		} else {
+			c.headers.Set(name, value)
+		}
+	}
+	for i, cookie := range cookies {
+		c.cookies = append(c.cookies, &cookieState{
+			index:  i,
+			jar:    newCookieJar(cookie),
+			health: CookieHealth{Label: cookieLabel(i, cookie), State: CookieActive},
+		})
+	}
+	for _, raw := range options.Proxies {
+		u, err := ParseProxy(raw)
+		if err != nil {
+			c.logger.Warn("忽略无效的代理", "err", err)
+			continue
+		}
+		c.proxies = append(c.proxies, &proxyState{url: u, health: ProxyHealth{Label: proxyLabel(u), Healthy: true}})
 	}
+	return c
 }
 
-// Get 发送GET请求并处理gzip压缩
-func (c *Client) Get(url string) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// cookieLabel 用于显示的 Cookie 标识：序号与 Cookie 的短哈希，便于区分又不泄露 Cookie 内容
+func cookieLabel(index int, cookie string) string {
+	sum := sha256.Sum256([]byte(cookie))
+	return fmt.Sprintf("#%d (%x)", index+1, sum[:3])
+}
+
+// Get 发送GET请求并解压响应，ctx 取消时中止请求
+//
+// 所有 Cookie 都在冷却或预算用完时等待最早恢复的一个；全部认证失败时直接返回错误。
+// 配置了代理时按 ProxyMode 选择代理，连接失败的代理退避一段时间。
+func (c *Client) Get(ctx context.Context, url string) ([]byte, error) {
+	state, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	proxy := c.pickProxy(state, time.Now())
+	c.mutex.Unlock()
+	via := "direct"
+	if proxy != nil {
+		ctx = context.WithValue(ctx, proxyKey{}, proxy)
+		via = proxy.health.Label
+	}
+
+	started := time.Now()
+	body, err := c.do(ctx, state, proxy, url)
+	attrs := []any{"url", url, "cookie", state.health.Label, "proxy", via, "latency", time.Since(started), "bytes", len(body)}
+	if err != nil {
+		attrs = append(attrs, "err", err)
+	}
+	c.logger.Debug("请求", attrs...)
+	if ctx.Err() == nil {
+		c.report(state, err)
+	}
+	return body, err
+}
+
+// do 用指定的 Cookie 与代理发送请求
+func (c *Client) do(ctx context.Context, state *cookieState, proxy *proxyState, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
-	c.setHeaders(req)
+	c.setHeaders(req, state)
 
 	resp, err := c.httpClient.Do(req)
+	if proxy != nil && ctx.Err() == nil {
+		c.reportProxy(proxy, err == nil && resp.StatusCode != http.StatusProxyAuthRequired)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		c.mutex.Lock()
+		state.jar.update(cookies, time.Now())
+		c.mutex.Unlock()
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("%w: HTTP状态码 %d", ErrAuth, resp.StatusCode)
+	case http.StatusRequestURITooLong, http.StatusTeapot, http.StatusTooManyRequests:
+		return nil, fmt.Errorf("%w: HTTP状态码 %d", ErrRateLimited, resp.StatusCode)
+	default:
 		return nil, fmt.Errorf("HTTP状态码错误: %d", resp.StatusCode)
 	}
+	// Cookie 失效时接口会跳转到登录页
+	if host := resp.Request.URL.Host; strings.HasPrefix(host, "passport.") || strings.HasPrefix(host, "login.") {
+		return nil, fmt.Errorf("%w: 被重定向到 %s", ErrAuth, host)
+	}
 
 	// 处理压缩响应
-	reader := c.getReader(resp.Body, resp.Header.Get("Content-Encoding"))
+	reader, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
 
 	body, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
+	if authExpired(body) {
+		return nil, fmt.Errorf("%w: 接口返回 ok=-100", ErrAuth)
+	}
 
 	return body, nil
 }
 
-// setHeaders 设置请求头
-func (c *Client) setHeaders(req *http.Request) {
-	req.Header.Set("Accept", "application/json, text/plain, */*")
-	req.Header.Set("Accept-Encoding", "gzip, deflate, br, zstd")
-	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
-	req.Header.Set("Client-Version", "v2.47.130")
-	req.Header.Set("Cookie", c.cookie)
-	req.Header.Set("Priority", "u=1, i")
-	req.Header.Set("Referer", "https://weibo.com")
-	req.Header.Set("Sec-CH-UA", `"Chromium";v="142", "Google Chrome";v="142", "Not_A Brand";v="99"`)
-	req.Header.Set("Sec-CH-UA-Mobile", "?0")
-	req.Header.Set("Sec-CH-UA-Platform", `"Linux"`)
-	req.Header.Set("Sec-Fetch-Dest", "empty")
-	req.Header.Set("Sec-Fetch-Mode", "cors")
-	req.Header.Set("Sec-Fetch-Site", "same-origin")
-	req.Header.Set("Server-Version", "v2025.10.31.1")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/142.0.0.0 Safari/537.36")
-	req.Header.Set("X-Requested-With", "XMLHttpRequest")
-	req.Header.Set("X-XSRF-TOKEN", "BRrWyvo1P2k853zXiY0UL7xp")
-}
-
-// getReader 根据Content-Encoding返回相应的Reader
-func (c *Client) getReader(body io.ReadCloser, encoding string) io.Reader {
-	if strings.Contains(encoding, "gzip") {
-		gzipReader, err := gzip.NewReader(body)
-		if err != nil {
-			// 如果解压失败，返回原始body
-			return body
+// authExpired Cookie 过期时接口仍返回 200，响应为 {"ok":-100,...}
+func authExpired(body []byte) bool {
+	var response struct {
+		OK json.RawMessage `json:"ok"`
+	}
+	return json.Unmarshal(body, &response) == nil && string(response.OK) == "-100"
+}
+
+// acquire 轮流选取可用的 Cookie，并计入其请求预算
+func (c *Client) acquire(ctx context.Context) (*cookieState, error) {
+	if len(c.cookies) == 0 {
+		return nil, utils.NewAuthError("没有可用的 Cookie", nil)
+	}
+	for {
+		c.mutex.Lock()
+		state, wait, err := c.pick(time.Now())
+		c.mutex.Unlock()
+		if state != nil || err != nil {
+			return state, err
+		}
+
+		c.logger.Info("全部 Cookie 冷却中或预算已用完，等待恢复", "wait", wait.Round(time.Second))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// pick 返回下一个可用的 Cookie；没有可用的时返回需要等待的时长，全部认证失败时返回错误
+func (c *Client) pick(now time.Time) (*cookieState, time.Duration, error) {
+	var wait time.Duration
+	allAuthFailed := true
+	for i := range c.cookies {
+		index := (c.next + i) % len(c.cookies)
+		state := c.cookies[index]
+
+		ready := state.readyAt(now, c.options.Budget)
+		if !ready.After(now) {
+			state.health.State = CookieActive
+			state.health.CooldownUntil = time.Time{}
+			if now.Sub(state.windowStart) >= budgetWindow {
+				state.windowStart, state.windowCount = now, 0
+			}
+			state.windowCount++
+			c.next = index + 1
+			return state, 0, nil
 		}
-		return gzipReader
+
+		if state.health.State != CookieAuthFailed {
+			allAuthFailed = false
+		}
+		if wait == 0 || ready.Sub(now) < wait {
+			wait = ready.Sub(now)
+		}
+	}
+	if allAuthFailed {
+		return nil, 0, utils.NewAuthError(fmt.Sprintf("全部 %d 个 Cookie 均认证失败", len(c.cookies)), ErrAuth)
+	}
+	return nil, wait, nil
+}
+
+// readyAt Cookie 恢复可用的时间
+func (s *cookieState) readyAt(now time.Time, budget int) time.Time {
+	if s.health.CooldownUntil.After(now) {
+		return s.health.CooldownUntil
+	}
+	if budget > 0 && s.windowCount >= budget && now.Sub(s.windowStart) < budgetWindow {
+		s.health.State = CookieExhausted
+		return s.windowStart.Add(budgetWindow)
+	}
+	return now
+}
+
+// report 记录请求结果，认证失败或触发频率限制时停用该 Cookie 一段时间
+func (c *Client) report(state *cookieState, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	health := &state.health
+	health.Requests++
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrAuth):
+		health.AuthErrors++
+		health.State = CookieAuthFailed
+		health.CooldownUntil = time.Now().Add(c.options.AuthCooldown)
+		c.logger.Warn("Cookie 认证失败，暂停使用", "cookie", health.Label, "cooldown", c.options.AuthCooldown, "err", err)
+	case errors.Is(err, ErrRateLimited):
+		health.RateLimits++
+		health.State = CookieRateLimited
+		health.CooldownUntil = time.Now().Add(c.options.Cooldown)
+		c.logger.Warn("Cookie 请求过于频繁，暂停使用", "cookie", health.Label, "cooldown", c.options.Cooldown, "err", err)
+	default:
+		health.Failures++
+	}
+}
+
+// Health 返回每个 Cookie 的请求统计与当前状态
+func (c *Client) Health() []CookieHealth {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	health := make([]CookieHealth, len(c.cookies))
+	for i, state := range c.cookies {
+		// 冷却结束但尚未被选中的 Cookie 显示为可用
+		if state.readyAt(now, c.options.Budget).Equal(now) {
+			state.health.State = CookieActive
+			state.health.CooldownUntil = time.Time{}
+		}
+		health[i] = state.health
+	}
+	return health
+}
+
+// setHeaders 设置请求头，Cookie 与 X-XSRF-TOKEN 取自该会话当前的 Cookie
+func (c *Client) setHeaders(req *http.Request, state *cookieState) {
+	for name, values := range c.headers {
+		req.Header[name] = values
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	req.Header.Set("Cookie", state.jar.header())
+	if token := state.jar.xsrf(); token != "" {
+		req.Header.Set("X-XSRF-TOKEN", token)
 	}
-	return body
 }
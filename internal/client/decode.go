@@ -0,0 +1,89 @@
+package client
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// decodeBody 按 Content-Encoding 解压响应，多个编码按相反顺序逐层解压
+//
+// 返回的 Reader 关闭时依次关闭各层解压器，不关闭 body。
+func decodeBody(body io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	var encodings []string
+	for _, encoding := range strings.Split(contentEncoding, ",") {
+		if encoding = strings.ToLower(strings.TrimSpace(encoding)); encoding != "" && encoding != "identity" {
+			encodings = append(encodings, encoding)
+		}
+	}
+
+	decoded := &decodedBody{Reader: body}
+	for i := len(encodings) - 1; i >= 0; i-- {
+		reader, err := newDecoder(decoded.Reader, encodings[i])
+		if err != nil {
+			decoded.Close()
+			return nil, fmt.Errorf("解压 %s 响应失败: %w", encodings[i], err)
+		}
+		decoded.Reader = reader
+		decoded.closers = append(decoded.closers, reader)
+	}
+	return decoded, nil
+}
+
+// newDecoder 创建一种编码的解压器
+func newDecoder(r io.Reader, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		return newDeflateReader(r)
+	case "br":
+		return io.NopCloser(brotli.NewReader(r)), nil
+	case "zstd":
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("不支持的编码 %q", encoding)
+	}
+}
+
+// newDeflateReader HTTP 的 deflate 应为 zlib 格式，但部分服务器发送不带 zlib 头的原始 deflate 数据
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	// zlib 头: CMF 的低 4 位为 8，且 CMF*256+FLG 能被 31 整除
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
+
+// decodedBody 解压后的响应，记录需要关闭的各层解压器
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close 从外到内关闭各层解压器
+func (d *decodedBody) Close() error {
+	var first error
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		if err := d.closers[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const fixture = `{"ok":1,"data":{"list":[{"source":"iPhone 15 Pro"}]}}`
+
+// compress 用指定编码压缩 fixture
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		var err error
+		if w, err = zstd.NewWriter(&buf); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		body     []byte
+		wantErr  bool
+		wantBody string
+	}{
+		{"identity", "", []byte(fixture), false, fixture},
+		{"gzip", "gzip", compress(t, "gzip", []byte(fixture)), false, fixture},
+		{"deflate", "deflate", compress(t, "deflate", []byte(fixture)), false, fixture},
+		{"raw deflate", "deflate", compress(t, "raw-deflate", []byte(fixture)), false, fixture},
+		{"brotli", "br", compress(t, "br", []byte(fixture)), false, fixture},
+		{"zstd", "zstd", compress(t, "zstd", []byte(fixture)), false, fixture},
+		{"stacked", "gzip, br", compress(t, "br", compress(t, "gzip", []byte(fixture))), false, fixture},
+		{"upper case", "GZIP", compress(t, "gzip", []byte(fixture)), false, fixture},
+		{"unsupported", "compress", []byte(fixture), true, ""},
+		{"corrupt gzip", "gzip", []byte(fixture), true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := decodeBody(bytes.NewReader(tt.body), tt.header)
+			if err == nil {
+				var body []byte
+				body, err = io.ReadAll(reader)
+				reader.Close()
+				if err == nil && string(body) != tt.wantBody {
+					t.Errorf("body = %q, want %q", body, tt.wantBody)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_DecodesResponse(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			compressed := compress(t, encoding, []byte(fixture))
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Encoding", encoding)
+				w.Write(compressed)
+			}))
+			defer ts.Close()
+
+			body, err := NewClient("SUB=a").Get(context.Background(), ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != fixture {
+				t.Errorf("body = %q, want %q", body, fixture)
+			}
+		})
+	}
+}
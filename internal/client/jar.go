@@ -0,0 +1,75 @@
+package client
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// xsrfCookie 保存 XSRF 令牌的 Cookie 名，请求时作为 X-XSRF-TOKEN 发送
+const xsrfCookie = "XSRF-TOKEN"
+
+// cookieJar 一个会话的 Cookie，按首次出现的顺序保存，响应中的 Set-Cookie 会更新或删除其中的值
+type cookieJar struct {
+	names  []string
+	values map[string]string
+}
+
+// newCookieJar 解析 "name=value; name2=value2" 形式的 Cookie
+func newCookieJar(cookie string) *cookieJar {
+	jar := &cookieJar{values: make(map[string]string)}
+	for _, part := range strings.Split(cookie, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && name != "" {
+			jar.set(name, value)
+		}
+	}
+	return jar
+}
+
+// set 设置一个 Cookie，已存在时保持原来的位置
+func (j *cookieJar) set(name, value string) {
+	if _, ok := j.values[name]; !ok {
+		j.names = append(j.names, name)
+	}
+	j.values[name] = value
+}
+
+// remove 删除一个 Cookie
+func (j *cookieJar) remove(name string) {
+	if _, ok := j.values[name]; !ok {
+		return
+	}
+	delete(j.values, name)
+	for i, n := range j.names {
+		if n == name {
+			j.names = append(j.names[:i], j.names[i+1:]...)
+			break
+		}
+	}
+}
+
+// update 应用响应中的 Set-Cookie，过期的 Cookie 被删除
+func (j *cookieJar) update(cookies []*http.Cookie, now time.Time) {
+	for _, cookie := range cookies {
+		if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(now)) {
+			j.remove(cookie.Name)
+			continue
+		}
+		j.set(cookie.Name, cookie.Value)
+	}
+}
+
+// header Cookie 请求头的值
+func (j *cookieJar) header() string {
+	parts := make([]string, len(j.names))
+	for i, name := range j.names {
+		parts[i] = name + "=" + j.values[name]
+	}
+	return strings.Join(parts, "; ")
+}
+
+// xsrf 当前的 XSRF 令牌，没有时返回空字符串
+func (j *cookieJar) xsrf() string {
+	return j.values[xsrfCookie]
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// 代理分配方式
+const (
+	ProxyRoundRobin = "round_robin" // 每个请求依次使用下一个代理（默认）
+	ProxySticky     = "sticky"      // 每个 Cookie 固定使用同一个代理，不可用时换下一个
+)
+
+// 代理失败后的退避时长，连续失败时翻倍
+const (
+	proxyBackoff    = 30 * time.Second
+	maxProxyBackoff = 10 * time.Minute
+)
+
+// ProxyHealth 一个代理的请求统计与当前状态
+type ProxyHealth struct {
+	Label        string    `json:"label"` // 代理地址，不含用户名与密码
+	Requests     int       `json:"requests"`
+	Failures     int       `json:"failures"` // 连接失败或代理认证失败
+	Healthy      bool      `json:"healthy"`
+	BackoffUntil time.Time `json:"backoff_until,omitempty"`
+}
+
+// proxyState 代理及其统计
+type proxyState struct {
+	url    *url.URL
+	health ProxyHealth
+	failed int // 连续失败次数
+}
+
+// proxyKey 在请求的 context 中保存本次使用的代理
+type proxyKey struct{}
+
+// ParseProxy 解析代理地址，支持 http、https、socks5 与 socks5h
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址 %q 无效: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("代理地址 %q 的协议不受支持，可选 http、https、socks5", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("代理地址 %q 缺少主机", raw)
+	}
+	return u, nil
+}
+
+// proxyLabel 不含用户名与密码的代理地址
+func proxyLabel(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// newTransport 按请求 context 中的代理转发，未指定时使用环境变量中的代理
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxy, ok := req.Context().Value(proxyKey{}).(*proxyState); ok {
+			return proxy.url, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+	return transport
+}
+
+// pickProxy 为使用该 Cookie 的请求选择代理，全部在退避中时使用最早恢复的一个
+//
+// 调用方需持有 c.mutex。
+func (c *Client) pickProxy(state *cookieState, now time.Time) *proxyState {
+	if len(c.proxies) == 0 {
+		return nil
+	}
+	start := c.nextProxy
+	if c.options.ProxyMode == ProxySticky {
+		start = state.index
+	}
+
+	var earliest *proxyState
+	for i := range c.proxies {
+		index := (start + i) % len(c.proxies)
+		proxy := c.proxies[index]
+		if !proxy.health.BackoffUntil.After(now) {
+			if c.options.ProxyMode != ProxySticky {
+				c.nextProxy = index + 1
+			}
+			return proxy
+		}
+		if earliest == nil || proxy.health.BackoffUntil.Before(earliest.health.BackoffUntil) {
+			earliest = proxy
+		}
+	}
+	return earliest
+}
+
+// reportProxy 记录代理的请求结果，失败时按连续失败次数退避
+func (c *Client) reportProxy(proxy *proxyState, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	health := &proxy.health
+	health.Requests++
+	if ok {
+		proxy.failed = 0
+		health.BackoffUntil = time.Time{}
+		return
+	}
+
+	health.Failures++
+	proxy.failed++
+	backoff := maxProxyBackoff
+	if proxy.failed <= 5 {
+		backoff = min(proxyBackoff<<(proxy.failed-1), maxProxyBackoff)
+	}
+	health.BackoffUntil = time.Now().Add(backoff)
+	c.logger.Warn("代理不可用，暂停使用", "proxy", health.Label, "backoff", backoff, "failures", proxy.failed)
+}
+
+// ProxyHealth 返回每个代理的请求统计与当前状态
+func (c *Client) ProxyHealth() []ProxyHealth {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	health := make([]ProxyHealth, len(c.proxies))
+	for i, proxy := range c.proxies {
+		health[i] = proxy.health
+		health[i].Healthy = !proxy.health.BackoffUntil.After(now)
+		if health[i].Healthy {
+			health[i].BackoffUntil = time.Time{}
+		}
+	}
+	return health
+}
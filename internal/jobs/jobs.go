@@ -0,0 +1,400 @@
+// Package jobs 管理通过 HTTP 提交的分析任务：排队执行、取消与历史记录
+package jobs
+
+import (
+	"comment_phone_analyse/analysis"
+	"comment_phone_analyse/config"
+	"comment_phone_analyse/internal/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobFile 任务目录中保存任务状态的文件
+const JobFile = "job.json"
+
+// queueSize 排队任务数上限
+const queueSize = 100
+
+// Status 任务状态
+type Status string
+
+// 任务状态
+const (
+	StatusQueued    Status = "queued"    // 排队中
+	StatusRunning   Status = "running"   // 执行中
+	StatusSucceeded Status = "succeeded" // 已完成
+	StatusFailed    Status = "failed"    // 执行失败
+	StatusCanceled  Status = "canceled"  // 已取消，执行中取消时保留已处理部分的结果
+)
+
+// Done 任务是否已结束
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCanceled
+}
+
+var (
+	// ErrNotFound 任务不存在
+	ErrNotFound = errors.New("任务不存在")
+	// ErrQueueFull 排队任务过多
+	ErrQueueFull = errors.New("排队任务过多，请稍后再试")
+	// ErrFinished 任务已结束，无法取消
+	ErrFinished = errors.New("任务已结束")
+)
+
+// Spec 提交任务时的参数，零值表示沿用服务的配置
+type Spec struct {
+	UID             string   `json:"uid"`
+	PostIDs         []string `json:"post_ids,omitempty"`
+	Limit           int      `json:"limit,omitempty"`
+	Interval        int      `json:"interval,omitempty"`
+	SampleStrategy  string   `json:"sample_strategy,omitempty"`
+	SamplePosts     int      `json:"sample_posts,omitempty"`
+	SamplePool      int      `json:"sample_pool,omitempty"`
+	SingleLimit     int      `json:"single_limit,omitempty"`
+	SinglePageLimit int      `json:"single_page_limit,omitempty"`
+	MinComments     int      `json:"min_comments,omitempty"`
+	PostKeyword     string   `json:"post_keyword,omitempty"`
+	PostSince       string   `json:"post_since,omitempty"`
+	PostUntil       string   `json:"post_until,omitempty"`
+}
+
+// config 在服务配置的基础上应用任务参数，输出写入任务目录
+func (s Spec) config(base *config.Config, dir string) (*config.Config, error) {
+	if s.UID == "" {
+		return nil, utils.NewConfigError("uid 不能为空", nil)
+	}
+	if s.Limit < 0 {
+		return nil, utils.NewConfigError("limit 不能为负数", nil)
+	}
+
+	cfg := *base
+	cfg.OutputDir = dir
+	cfg.UID = s.UID
+	if len(s.PostIDs) > 0 {
+		cfg.PostIDs = s.PostIDs
+	}
+	if s.Limit != 0 {
+		cfg.Limit = s.Limit
+	}
+	if s.Interval != 0 {
+		cfg.Interval = s.Interval
+	}
+	if s.SampleStrategy != "" {
+		cfg.SampleStrategy = s.SampleStrategy
+	}
+	if s.SamplePosts != 0 {
+		cfg.SamplePosts = s.SamplePosts
+	}
+	if s.SamplePool != 0 {
+		cfg.SamplePool = s.SamplePool
+	}
+	if s.SingleLimit != 0 {
+		cfg.SingleLimit = s.SingleLimit
+	}
+	if s.SinglePageLimit != 0 {
+		cfg.SinglePageLimit = s.SinglePageLimit
+	}
+	if s.MinComments != 0 {
+		cfg.MinComments = s.MinComments
+	}
+	if s.PostKeyword != "" {
+		cfg.PostKeyword = s.PostKeyword
+	}
+	if s.PostSince != "" {
+		cfg.PostSince = s.PostSince
+	}
+	if s.PostUntil != "" {
+		cfg.PostUntil = s.PostUntil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Job 一个分析任务
+type Job struct {
+	ID         string           `json:"id"`
+	Spec       Spec             `json:"spec"`
+	Status     Status           `json:"status"`
+	Error      string           `json:"error,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	OutputDir  string           `json:"output_dir"`       // 任务目录
+	Result     *analysis.Result `json:"result,omitempty"` // 结束后的结果
+}
+
+// Runner 执行一次分析并导出结果，ctx 取消时返回已处理部分的结果与 ctx.Err()
+type Runner func(ctx context.Context, cfg *config.Config) (*analysis.Result, error)
+
+// Manager 任务队列，任务状态保存在 <dir>/<任务ID>/job.json
+type Manager struct {
+	base *config.Config
+	dir  string
+	run  Runner
+	wake chan struct{} // 有任务入队时通知空闲的执行协程
+
+	mutex   sync.Mutex
+	queue   []string // 排队中的任务ID，取消的任务立即移出
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewManager 创建任务队列并加载历史任务
+//
+// 上次退出时仍在排队的任务重新排队，执行中的任务标记为失败。
+func NewManager(base *config.Config, dir string, run Runner) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, utils.NewConfigError("创建任务目录失败", err)
+	}
+	m := &Manager{
+		base:    base,
+		dir:     dir,
+		run:     run,
+		wake:    make(chan struct{}, 1),
+		jobs:    make(map[string]*Job),
+		cancels: make(map[string]context.CancelFunc),
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*", JobFile))
+	var pending []*Job
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID == "" {
+			slog.Warn("跳过无法解析的任务文件", "file", file, "err", err)
+			continue
+		}
+		switch job.Status {
+		case StatusQueued:
+			pending = append(pending, &job)
+		case StatusRunning:
+			job.Status = StatusFailed
+			job.Error = "服务退出时任务仍在执行"
+			job.FinishedAt = time.Now()
+			m.save(&job)
+		}
+		m.jobs[job.ID] = &job
+	}
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	for _, job := range pending {
+		m.queue = append(m.queue, job.ID)
+	}
+	return m, nil
+}
+
+// Start 启动 workers 个执行协程，ctx 取消时取消执行中的任务并停止，排队的任务留到下次启动
+func (m *Manager) Start(ctx context.Context, workers int) {
+	for i := 0; i < max(workers, 1); i++ {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			for {
+				if id, ok := m.next(); ok {
+					m.execute(ctx, id)
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-m.wake:
+				}
+			}
+		}()
+	}
+}
+
+// next 取出下一个排队的任务，队列中还有任务时继续唤醒其他空闲的执行协程
+func (m *Manager) next() (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(m.queue) == 0 {
+		return "", false
+	}
+	id := m.queue[0]
+	m.queue = m.queue[1:]
+	if len(m.queue) > 0 {
+		m.notify()
+	}
+	return id, true
+}
+
+// notify 唤醒一个空闲的执行协程
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Wait 等待执行协程退出
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Submit 校验参数并将任务加入队列
+func (m *Manager) Submit(spec Spec) (Job, error) {
+	id := newID()
+	dir := filepath.Join(m.dir, id)
+	if _, err := spec.config(m.base, dir); err != nil {
+		return Job{}, err
+	}
+
+	job := &Job{
+		ID:        id,
+		Spec:      spec,
+		Status:    StatusQueued,
+		CreatedAt: time.Now(),
+		OutputDir: dir,
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(m.queue) >= queueSize {
+		return Job{}, ErrQueueFull
+	}
+	m.queue = append(m.queue, id)
+	m.notify()
+	m.jobs[id] = job
+	if err := m.save(job); err != nil {
+		slog.Error("保存任务失败", "job", id, "err", err)
+	}
+	return *job, nil
+}
+
+// Get 获取任务
+func (m *Manager) Get(id string) (Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return *job, nil
+}
+
+// List 列出任务，最新提交的在前，status 为空时列出全部
+func (m *Manager) List(status Status) []Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if status == "" || job.Status == status {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs
+}
+
+// Cancel 取消排队或执行中的任务；执行中的任务在停止后才变为 canceled
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	switch {
+	case job.Status.Done():
+		return *job, ErrFinished
+	case job.Status == StatusQueued:
+		// 移出队列，不再占用排队名额
+		m.queue = slices.DeleteFunc(m.queue, func(queued string) bool { return queued == id })
+		job.Status = StatusCanceled
+		job.FinishedAt = time.Now()
+		m.save(job)
+	default:
+		m.cancels[id]()
+	}
+	return *job, nil
+}
+
+// execute 执行一个排队的任务
+func (m *Manager) execute(ctx context.Context, id string) {
+	m.mutex.Lock()
+	job, ok := m.jobs[id]
+	// 服务退出时出队的任务保持排队状态，下次启动时执行
+	if !ok || job.Status != StatusQueued || ctx.Err() != nil {
+		m.mutex.Unlock()
+		return
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m.cancels[id] = cancel
+	job.Status = StatusRunning
+	job.StartedAt = time.Now()
+	m.save(job)
+	spec := job.Spec
+	m.mutex.Unlock()
+
+	var result *analysis.Result
+	cfg, err := spec.config(m.base, job.OutputDir)
+	if err == nil {
+		result, err = m.run(jobCtx, cfg)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.cancels, id)
+	job.FinishedAt = time.Now()
+	job.Result = result
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.Status = StatusCanceled
+		if ctx.Err() != nil {
+			job.Error = "服务退出，任务已取消"
+		}
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	default:
+		job.Status = StatusSucceeded
+	}
+	if err := m.save(job); err != nil {
+		slog.Error("保存任务失败", "job", id, "err", err)
+	}
+}
+
+// save 写入任务文件，先写临时文件再重命名，避免中断时留下不完整的文件
+func (m *Manager) save(job *Job) error {
+	dir := filepath.Join(m.dir, job.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return utils.NewExportError("创建任务目录失败", err)
+	}
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return utils.NewExportError("序列化任务失败", err)
+	}
+	tmp := filepath.Join(dir, JobFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return utils.NewExportError("写入任务文件失败", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, JobFile)); err != nil {
+		return utils.NewExportError("写入任务文件失败", err)
+	}
+	return nil
+}
+
+// newID 生成按时间排序的任务ID
+func newID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
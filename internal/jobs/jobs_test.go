@@ -0,0 +1,228 @@
+package jobs
+
+import (
+	"comment_phone_analyse/analysis"
+	"comment_phone_analyse/config"
+	"comment_phone_analyse/internal/models"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testBase() *config.Config {
+	return &config.Config{Cookie: "SUB=x", Limit: 100}
+}
+
+// blockingRunner 在收到 release 或取消前阻塞，started 通知开始执行
+func blockingRunner(started chan<- *config.Config, release <-chan struct{}) Runner {
+	return func(ctx context.Context, cfg *config.Config) (*analysis.Result, error) {
+		started <- cfg
+		result := &analysis.Result{Run: models.RunInfo{UID: cfg.UID}, OutputDir: cfg.OutputDir}
+		select {
+		case <-release:
+			return result, nil
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+}
+
+// waitStatus 等待任务进入指定状态
+func waitStatus(t *testing.T, m *Manager, id string, status Status) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := m.Get(id)
+	t.Fatalf("job %s status = %s, want %s", id, job.Status, status)
+	return job
+}
+
+func TestManager_Run(t *testing.T) {
+	dir := t.TempDir()
+	started := make(chan *config.Config, 1)
+	release := make(chan struct{})
+	m, err := NewManager(testBase(), dir, blockingRunner(started, release))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); m.Wait() }()
+	m.Start(ctx, 1)
+
+	job, err := m.Submit(Spec{UID: "42", Limit: 5, PostIDs: []string{"A"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := <-started
+	if cfg.UID != "42" || cfg.Limit != 5 || len(cfg.PostIDs) != 1 || cfg.OutputDir != filepath.Join(dir, job.ID) {
+		t.Errorf("job config = %+v", cfg)
+	}
+	if cfg.Interval != testBase().Interval {
+		t.Errorf("interval = %d, want base value", cfg.Interval)
+	}
+	waitStatus(t, m, job.ID, StatusRunning)
+
+	close(release)
+	done := waitStatus(t, m, job.ID, StatusSucceeded)
+	if done.Result == nil || done.Result.Run.UID != "42" || done.FinishedAt.IsZero() {
+		t.Errorf("finished job = %+v", done)
+	}
+	if _, err := m.Cancel(job.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("Cancel(finished) error = %v, want ErrFinished", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, job.ID, JobFile)); err != nil {
+		t.Errorf("job file not saved: %v", err)
+	}
+}
+
+func TestManager_Submit_Invalid(t *testing.T) {
+	m, err := NewManager(testBase(), t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []Spec{{}, {UID: "42", Limit: -1}, {UID: "42", PostSince: "昨天"}} {
+		if _, err := m.Submit(spec); err == nil {
+			t.Errorf("Submit(%+v) succeeded, want error", spec)
+		}
+	}
+	if jobs := m.List(""); len(jobs) != 0 {
+		t.Errorf("invalid specs were queued: %+v", jobs)
+	}
+}
+
+func TestManager_Cancel(t *testing.T) {
+	started := make(chan *config.Config, 2)
+	m, err := NewManager(testBase(), t.TempDir(), blockingRunner(started, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); m.Wait() }()
+	m.Start(ctx, 1)
+
+	running, _ := m.Submit(Spec{UID: "1"})
+	<-started
+	queued, _ := m.Submit(Spec{UID: "2"})
+
+	// 排队中的任务立即取消，之后不会执行
+	if job, err := m.Cancel(queued.ID); err != nil || job.Status != StatusCanceled {
+		t.Fatalf("Cancel(queued) = %+v, %v", job, err)
+	}
+	if _, err := m.Cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	job := waitStatus(t, m, running.ID, StatusCanceled)
+	if job.Result == nil {
+		t.Error("canceled job lost its partial result")
+	}
+
+	select {
+	case cfg := <-started:
+		t.Errorf("canceled job %s was executed", cfg.UID)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := m.Cancel("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel(missing) error = %v, want ErrNotFound", err)
+	}
+	if jobs := m.List(StatusCanceled); len(jobs) != 2 {
+		t.Errorf("List(canceled) = %d jobs, want 2", len(jobs))
+	}
+}
+
+func TestNewManager_LoadsHistory(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewManager(testBase(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interrupted, _ := m.Submit(Spec{UID: "1"})
+	queued, _ := m.Submit(Spec{UID: "2"})
+	// 模拟进程在任务执行中退出
+	m.jobs[interrupted.ID].Status = StatusRunning
+	if err := m.save(m.jobs[interrupted.ID]); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan *config.Config, 1)
+	release := make(chan struct{})
+	close(release)
+	reloaded, err := NewManager(testBase(), dir, blockingRunner(started, release))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := reloaded.Get(interrupted.ID); job.Status != StatusFailed {
+		t.Errorf("interrupted job status = %s, want failed", job.Status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); reloaded.Wait() }()
+	reloaded.Start(ctx, 1)
+	if cfg := <-started; cfg.UID != "2" {
+		t.Errorf("requeued job uid = %s, want 2", cfg.UID)
+	}
+	waitStatus(t, reloaded, queued.ID, StatusSucceeded)
+}
+
+func TestManager_CancelFreesQueueSlot(t *testing.T) {
+	m, err := NewManager(testBase(), t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first Job
+	for i := 0; i < queueSize; i++ {
+		job, err := m.Submit(Spec{UID: "1"})
+		if err != nil {
+			t.Fatalf("Submit(%d) error = %v", i, err)
+		}
+		if i == 0 {
+			first = job
+		}
+	}
+	if _, err := m.Submit(Spec{UID: "1"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit() on a full queue error = %v, want ErrQueueFull", err)
+	}
+
+	// 取消排队中的任务后腾出名额
+	if _, err := m.Cancel(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Submit(Spec{UID: "1"}); err != nil {
+		t.Errorf("Submit() after cancel error = %v", err)
+	}
+}
+
+func TestManager_CancelAfterRunnerSucceeded(t *testing.T) {
+	started := make(chan *config.Config, 1)
+	// 取消到达时分析已完成，执行函数忽略取消并正常返回
+	run := func(ctx context.Context, cfg *config.Config) (*analysis.Result, error) {
+		started <- cfg
+		<-ctx.Done()
+		return &analysis.Result{Run: models.RunInfo{UID: cfg.UID}}, nil
+	}
+	m, err := NewManager(testBase(), t.TempDir(), run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); m.Wait() }()
+	m.Start(ctx, 1)
+
+	job, _ := m.Submit(Spec{UID: "1"})
+	<-started
+	if _, err := m.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, job.ID, StatusSucceeded)
+}
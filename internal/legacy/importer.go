@@ -0,0 +1,109 @@
+package legacy
+
+import (
+	"comment_phone_analyse/export"
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/storage"
+	"comment_phone_analyse/internal/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 导出的品牌汇总文件名
+const BrandCountsFile = "brand_counts.jsonl"
+
+// Reclassify 返回按当前映射重新分类后的数据集，原数据集不变
+func (d *Dataset) Reclassify(mapping models.PhoneBrandMapping) *Dataset {
+	result := *d
+	if d.Users != nil {
+		result.Users = make([]models.UserInfo, len(d.Users))
+		for i, user := range d.Users {
+			user.PhoneType = models.ClassifySource(mapping, user.Source)
+			result.Users[i] = user
+		}
+	}
+	if d.Counts != nil {
+		result.Counts = make(map[string]int, len(d.Counts))
+		for source, count := range d.Counts {
+			result.Counts[models.ClassifySource(mapping, source)] += count
+		}
+	}
+	return &result
+}
+
+// ImportToStore 将数据集作为一次运行写入数据库，同一文件已导入过时跳过并返回已有运行ID
+//
+// 整个导入在一个事务中完成，中途失败不会留下没有导入记录的运行。
+func ImportToStore(store *storage.Store, dataset *Dataset) (int64, bool, error) {
+	source, err := filepath.Abs(dataset.Path)
+	if err != nil {
+		source = dataset.Path
+	}
+	if runID, err := store.ImportedRun(source); err != nil || runID != 0 {
+		return runID, false, err
+	}
+
+	// 旧版输出没有运行时间，以文件修改时间代替
+	run := models.RunInfo{
+		UID:            dataset.Target,
+		SampleSize:     dataset.Size(),
+		SampleStrategy: "legacy-" + string(dataset.Format),
+	}
+	if info, err := os.Stat(dataset.Path); err == nil {
+		run.StartedAt = info.ModTime()
+		run.FinishedAt = info.ModTime()
+	}
+
+	runID, err := store.ImportRun(run, dataset.Users, dataset.Counts, source)
+	if err != nil {
+		return 0, false, err
+	}
+	return runID, true, nil
+}
+
+// brandCount 品牌汇总文件中的一行
+type brandCount struct {
+	Brand string `json:"brand"`
+	Count int    `json:"count"`
+}
+
+// WriteJSONL 将数据集写入 dir/<名称>/ 下：逐用户数据写 users.jsonl，快照数据写 brand_counts.jsonl
+func WriteJSONL(dir string, dataset *Dataset) (string, error) {
+	targetDir := filepath.Join(dir, dataset.Name)
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return "", utils.NewExportError("创建导入目录失败", err)
+	}
+
+	if dataset.Counts == nil {
+		filename := filepath.Join(targetDir, export.JSONLRecordsFile)
+		writer, err := export.NewJSONLRecordWriter(filename)
+		if err != nil {
+			return "", err
+		}
+		defer writer.Close()
+		for i := range dataset.Users {
+			if err := writer.Write(&dataset.Users[i]); err != nil {
+				return "", err
+			}
+		}
+		return filename, nil
+	}
+
+	filename := filepath.Join(targetDir, BrandCountsFile)
+	file, err := os.Create(filename)
+	if err != nil {
+		return "", utils.NewExportError("创建品牌汇总文件失败", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	for _, stat := range models.SortedCounts(dataset.Counts) {
+		if err := encoder.Encode(brandCount{Brand: stat.PhoneType, Count: stat.Count}); err != nil {
+			return "", utils.NewExportError(fmt.Sprintf("写入 %s 失败", filename), err)
+		}
+	}
+	return filename, nil
+}
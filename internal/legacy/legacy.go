@@ -0,0 +1,208 @@
+// Package legacy 解析旧版本程序留下的统计输出，重新分类后导入数据库或 JSONL
+package legacy
+
+import (
+	"bufio"
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/utils"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format 旧版输出格式
+type Format string
+
+// 支持的旧版输出格式
+const (
+	FormatSnapshots   Format = "snapshots"    // statistics-data/*.out：多次“PhoneType: X, Num: N”快照
+	FormatSourceLines Format = "source_lines" // out-android-device-*.txt：每行“uid 来源”
+	FormatBrandLines  Format = "brand_lines"  // output/<uid>/stats.txt：每行“uid:品牌”
+)
+
+// Dataset 从一个旧版文件中解析出的数据
+//
+// 逐用户格式填充 Users（Source 为原始来源），快照格式只有 Counts（原始来源 → 数量）。
+type Dataset struct {
+	Path   string
+	Name   string // 导出目录名，同一目标的不同文件互不覆盖
+	Target string // 目标用户名或UID
+	Format Format
+	Users  []models.UserInfo
+	Counts map[string]int
+}
+
+// Size 数据集包含的用户数
+func (d *Dataset) Size() int {
+	if d.Counts == nil {
+		return len(d.Users)
+	}
+	total := 0
+	for _, count := range d.Counts {
+		total += count
+	}
+	return total
+}
+
+// Detect 根据文件名判断旧版输出格式，无法识别时返回空字符串
+func Detect(path string) Format {
+	base := filepath.Base(path)
+	switch {
+	case strings.HasSuffix(base, ".out"):
+		return FormatSnapshots
+	case strings.HasPrefix(base, "out-android-device-") && strings.HasSuffix(base, ".txt"):
+		return FormatSourceLines
+	case base == "stats.txt":
+		return FormatBrandLines
+	}
+	return ""
+}
+
+// Collect 展开目录，返回其中所有可识别的旧版输出文件；直接给出的文件必须可识别
+func Collect(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, utils.NewNotFoundError(fmt.Sprintf("找不到 %s", path), err)
+		}
+		if !info.IsDir() {
+			if Detect(path) == "" {
+				return nil, utils.NewParseError(fmt.Sprintf("无法识别的旧版输出文件: %s", path), nil)
+			}
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && Detect(p) != "" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, utils.NewParseError(fmt.Sprintf("遍历目录 %s 失败", path), err)
+		}
+	}
+	return files, nil
+}
+
+// ParseFile 按文件名识别格式并解析
+func ParseFile(path string) (*Dataset, error) {
+	format := Detect(path)
+	if format == "" {
+		return nil, utils.NewParseError(fmt.Sprintf("无法识别的旧版输出文件: %s", path), nil)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, utils.NewParseError(fmt.Sprintf("打开 %s 失败", path), err)
+	}
+	defer file.Close()
+
+	dataset := &Dataset{Path: path, Format: format}
+	base := filepath.Base(path)
+	switch format {
+	case FormatSnapshots:
+		dataset.Target = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSuffix(base, ".out"), "旧-"), "out-")
+		dataset.Name = strings.TrimSuffix(base, ".out")
+		dataset.Counts, err = ParseSnapshots(file)
+	case FormatSourceLines:
+		dataset.Target = strings.TrimPrefix(strings.TrimSuffix(base, ".txt"), "out-android-device-")
+		dataset.Name = strings.TrimSuffix(base, ".txt")
+		dataset.Users, err = parseUserLines(file, " ")
+	case FormatBrandLines:
+		dataset.Target = filepath.Base(filepath.Dir(path))
+		dataset.Name = dataset.Target
+		dataset.Users, err = parseUserLines(file, ":")
+	}
+	if err != nil {
+		return nil, utils.NewParseError(fmt.Sprintf("解析 %s 失败", path), err)
+	}
+	return dataset, nil
+}
+
+// 快照格式中的行
+var (
+	phoneTypeLine = regexp.MustCompile(`^PhoneType: (.*), Num: (\d+)$`)
+	finalHeader   = regexp.MustCompile(`^=+最终统计结果`)
+	separatorLine = regexp.MustCompile(`^=+$`)
+)
+
+// ParseSnapshots 解析“PhoneType: X, Num: N”格式的统计输出
+//
+// 文件由多次累计快照组成，以 ===== 分隔，后面的快照覆盖前面的。较新的文件末尾有
+// “最终统计结果：未知机型/已知机型”两段，存在时以两段之和为准；否则取最后一个完整快照。
+// 混在其中的日志、HTML 错误页等无关行会被忽略。
+func ParseSnapshots(r io.Reader) (map[string]int, error) {
+	var last, current, final map[string]int
+	inFinal := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case finalHeader.MatchString(line):
+			inFinal = true
+			if final == nil {
+				final = make(map[string]int)
+			}
+		case separatorLine.MatchString(line):
+			if !inFinal && current != nil {
+				last, current = current, nil
+			}
+		default:
+			match := phoneTypeLine.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			count, err := strconv.Atoi(match[2])
+			if err != nil {
+				return nil, err
+			}
+			if inFinal {
+				final[match[1]] += count
+				continue
+			}
+			if current == nil {
+				current = make(map[string]int)
+			}
+			current[match[1]] = count
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(final) > 0 {
+		return final, nil
+	}
+	if last == nil {
+		return map[string]int{}, nil
+	}
+	return last, nil
+}
+
+// parseUserLines 解析“uid<分隔符>来源”格式，跳过空行和UID不是数字的行
+func parseUserLines(r io.Reader, sep string) ([]models.UserInfo, error) {
+	var users []models.UserInfo
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		uid, source, ok := strings.Cut(strings.TrimSpace(scanner.Text()), sep)
+		if !ok || !utils.IsNumeric(uid) {
+			continue
+		}
+		source = strings.TrimSpace(source)
+		users = append(users, models.UserInfo{Id: uid, PhoneType: source, Source: source})
+	}
+	return users, scanner.Err()
+}
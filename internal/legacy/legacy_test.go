@@ -0,0 +1,102 @@
+package legacy
+
+import (
+	"comment_phone_analyse/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSnapshots(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]int
+	}{
+		{
+			name: "新版带最终结果",
+			input: `统计中...
+PhoneType: 苹果, Num: 1
+cnt: 1
+=====================================
+统计中...
+PhoneType: 苹果, Num: 2
+cnt: 2
+=====================================
+==========================最终统计结果：未知机型============================
+PhoneType: <a href="x">Note 10 Pro</a>, Num: 1
+PhoneType: , Num: 3
+==========================最终统计结果：已知机型============================
+PhoneType: 苹果, Num: 2
+PhoneType: 未知Android设备, Num: 1
+`,
+			want: map[string]int{`<a href="x">Note 10 Pro</a>`: 1, "": 3, "苹果": 2, "未知Android设备": 1},
+		},
+		{
+			name: "旧版取最后一个快照并忽略错误输出",
+			input: `PhoneType: 苹果, Num: 1
+=====================================
+cnt: 1
+Response Body: <!DOCTYPE html>
+PhoneType: 苹果, Num: 2
+PhoneType: Vivo, Num: 1
+=====================================
+cnt: 3
+panic: runtime error: invalid memory address or nil pointer dereference
+`,
+			want: map[string]int{"苹果": 2, "Vivo": 1},
+		},
+		{
+			name:  "未完成的快照不计入",
+			input: "PhoneType: 苹果, Num: 1\n",
+			want:  map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSnapshots(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ParseSnapshots() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSnapshots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUserLines(t *testing.T) {
+	input := "6616210029 菠萝派Android\n\nnot-a-uid x\n6121753069 Android客户端\n"
+	got, err := parseUserLines(strings.NewReader(input), " ")
+	if err != nil {
+		t.Fatalf("parseUserLines() error = %v", err)
+	}
+	want := []models.UserInfo{
+		{Id: "6616210029", PhoneType: "菠萝派Android", Source: "菠萝派Android"},
+		{Id: "6121753069", PhoneType: "Android客户端", Source: "Android客户端"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseUserLines() = %v, want %v", got, want)
+	}
+}
+
+func TestDataset_Reclassify(t *testing.T) {
+	mapping := models.PhoneBrandMapping{"iPhone": "苹果", "Android": "Android设备"}
+	dataset := &Dataset{
+		Users:  []models.UserInfo{{Id: "1", Source: `<a href="x">iPhone 15</a>`}},
+		Counts: map[string]int{"iPhone客户端": 2, "苹果": 1, "": 4, "星辰大海": 1},
+	}
+
+	got := dataset.Reclassify(mapping)
+	if got.Users[0].PhoneType != "苹果" {
+		t.Errorf("Users[0].PhoneType = %q, want 苹果", got.Users[0].PhoneType)
+	}
+	want := map[string]int{"苹果": 3, "未知设备": 4, "星辰大海": 1}
+	if !reflect.DeepEqual(got.Counts, want) {
+		t.Errorf("Counts = %v, want %v", got.Counts, want)
+	}
+	if dataset.Users[0].PhoneType != "" {
+		t.Error("Reclassify() should not modify the original dataset")
+	}
+}
@@ -0,0 +1,29 @@
+package legacy
+
+import (
+	"comment_phone_analyse/export"
+	"comment_phone_analyse/internal/models"
+	"os"
+	"path/filepath"
+)
+
+// ReadDir 读取输出目录中的用户记录；没有 users.jsonl 与 stats.csv 时解析旧版的 stats.txt
+//
+// 旧版记录只有来源原文，PhoneType 与 Source 相同，需要重新分类。
+func ReadDir(dir string) ([]models.UserInfo, error) {
+	for _, name := range []string{export.JSONLRecordsFile, export.CSVRecordsFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return export.ReadRecords(dir)
+		}
+	}
+
+	path := filepath.Join(dir, "stats.txt")
+	if _, err := os.Stat(path); err != nil {
+		return export.ReadRecords(dir)
+	}
+	dataset, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return dataset.Users, nil
+}
@@ -0,0 +1,113 @@
+// Package logging 基于 log/slog 的日志：控制台输出与每次运行的 run.log
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// 日志格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ParseLevel 解析日志级别：debug、info、warn、error，为空时为 info
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("未知的日志级别 %q，可选 debug、info、warn、error", s)
+	}
+}
+
+// NewHandler 创建写入 w 的文本或 JSON 日志处理器
+func NewHandler(w io.Writer, format string, level slog.Leveler) (slog.Handler, error) {
+	options := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "", FormatText:
+		return slog.NewTextHandler(w, options), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, options), nil
+	default:
+		return nil, fmt.Errorf("未知的日志格式 %q，可选 %s、%s", format, FormatText, FormatJSON)
+	}
+}
+
+// Setup 将默认日志输出到标准错误，与标准输出上的结果分开
+func Setup(format string, level slog.Level) error {
+	handler, err := NewHandler(os.Stderr, format, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// OpenFile 创建（或清空）日志文件，返回同时写入该文件与 base 的日志及关闭文件的函数
+func OpenFile(base *slog.Logger, path, format string, level slog.Level) (*slog.Logger, io.Closer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	handler, err := NewHandler(file, format, level)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return slog.New(Tee(base.Handler(), handler)), file, nil
+}
+
+// Tee 将每条日志交给全部处理器，各处理器按自己的级别过滤
+func Tee(handlers ...slog.Handler) slog.Handler {
+	return teeHandler(handlers)
+}
+
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
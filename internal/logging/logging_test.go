@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for input, want := range map[string]slog.Level{"": slog.LevelInfo, "DEBUG": slog.LevelDebug, "warn": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(input); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("trace"); err == nil {
+		t.Error("ParseLevel(trace) should fail")
+	}
+	if _, err := NewHandler(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Error("NewHandler(xml) should fail")
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	var console bytes.Buffer
+	base := slog.New(slog.NewTextHandler(&console, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	path := filepath.Join(t.TempDir(), "run.log")
+
+	logger, closer, err := OpenFile(base, path, FormatJSON, slog.LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger = logger.With("uid", "42")
+	logger.Debug("请求", "endpoint", "statuses/mymblog")
+	logger.Warn("失败", "attempt", 2)
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 控制台只有 warn，文件按自己的级别记录全部日志
+	if strings.Contains(console.String(), "请求") || !strings.Contains(console.String(), "uid=42") {
+		t.Errorf("console = %q", console.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("run.log has %d lines, want 2: %s", len(lines), data)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["uid"] != "42" || entry["endpoint"] != "statuses/mymblog" || entry["level"] != "DEBUG" {
+		t.Errorf("entry = %v", entry)
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import "sort"
+
+// BrandDelta 两次运行中同一品牌的人数与占比变化
+type BrandDelta struct {
+	Brand       string  `json:"brand"`
+	Before      int     `json:"before"`
+	After       int     `json:"after"`
+	BeforeShare float64 `json:"before_share"` // 前一次运行中的占比（百分比）
+	AfterShare  float64 `json:"after_share"`  // 后一次运行中的占比（百分比）
+}
+
+// Change 占比变化的百分点
+func (d BrandDelta) Change() float64 {
+	return d.AfterShare - d.BeforeShare
+}
+
+// CompareBrands 对比两次运行的品牌分布，按后一次运行的人数从多到少排列
+func CompareBrands(before, after *PhoneStatistics) []BrandDelta {
+	brands := make(map[string]bool)
+	for brand := range before.BrandCounts {
+		brands[brand] = true
+	}
+	for brand := range after.BrandCounts {
+		brands[brand] = true
+	}
+
+	deltas := make([]BrandDelta, 0, len(brands))
+	for brand := range brands {
+		deltas = append(deltas, BrandDelta{
+			Brand:       brand,
+			Before:      before.BrandCounts[brand],
+			After:       after.BrandCounts[brand],
+			BeforeShare: share(before.BrandCounts[brand], before.UserCount),
+			AfterShare:  share(after.BrandCounts[brand], after.UserCount),
+		})
+	}
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].After != deltas[j].After {
+			return deltas[i].After > deltas[j].After
+		}
+		if deltas[i].Before != deltas[j].Before {
+			return deltas[i].Before > deltas[j].Before
+		}
+		return deltas[i].Brand < deltas[j].Brand
+	})
+	return deltas
+}
+
+// share 计算百分比，总数为 0 时返回 0
+func share(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total) * 100
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"comment_phone_analyse/internal/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadPhoneMapping 读取品牌映射文件并合并到默认映射上，path 为空时返回默认映射
+//
+// 映射文件是“来源关键字 → 品牌”的 JSON 对象，与 PhoneBrandMapping 结构相同，
+// 同名关键字以文件为准，例如 {"SM-S9180": "三星", "PJA110": "OPPO"}。
+func LoadPhoneMapping(path string) (PhoneBrandMapping, error) {
+	mapping := DefaultPhoneMapping()
+	if path == "" {
+		return mapping, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, utils.NewConfigError(fmt.Sprintf("读取品牌映射文件 %s 失败", path), err)
+	}
+	var rules PhoneBrandMapping
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, utils.NewConfigError(fmt.Sprintf("解析品牌映射文件 %s 失败", path), err)
+	}
+	for key, brand := range rules {
+		if strings.TrimSpace(key) == "" || strings.TrimSpace(brand) == "" {
+			return nil, utils.NewConfigError(fmt.Sprintf("品牌映射文件 %s 含有空的关键字或品牌", path), nil)
+		}
+		mapping[key] = brand
+	}
+	return mapping, nil
+}
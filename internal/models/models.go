@@ -1,6 +1,10 @@
 package models
 
-import "strings"
+import (
+	"regexp"
+	"strings"
+	"time"
+)
 
 // BlogResponse 博客列表响应
 type BlogResponse struct {
@@ -14,10 +18,22 @@ type BlogData struct {
 
 // Blog 博客信息
 type Blog struct {
-	ID        string `json:"idstr"`
-	MblogID   string `json:"mblogid"`
-	PhoneType string `json:"source"`
-	User      User   `json:"user"`
+	ID            string `json:"idstr"`
+	MblogID       string `json:"mblogid"`
+	PhoneType     string `json:"source"`
+	CommentsCount int    `json:"comments_count"`
+	CreatedAt     string `json:"created_at"` // 如 "Mon Oct 20 12:00:00 +0800 2025"
+	Text          string `json:"text_raw"`
+	User          User   `json:"user"`
+}
+
+// blogTimeLayout 微博接口 created_at 的时间格式
+const blogTimeLayout = "Mon Jan 02 15:04:05 -0700 2006"
+
+// Created 解析博客发布时间，缺失或格式不符时返回 false
+func (b Blog) Created() (time.Time, bool) {
+	t, err := time.Parse(blogTimeLayout, b.CreatedAt)
+	return t, err == nil
 }
 
 // User 用户信息
@@ -45,6 +61,38 @@ type CommentUser struct {
 type PhoneStatistics struct {
 	BrandCounts map[string]int `json:"brand_counts"`
 	UserCount   int            `json:"user_count"`
+
+	// 各维度人数
+	GenderCounts     map[string]int `json:"gender_counts"`
+	LocationCounts   map[string]int `json:"location_counts"`
+	IPLocationCounts map[string]int `json:"ip_location_counts"`
+
+	// 品牌与各维度的交叉统计
+	BrandGender     CrossTab `json:"brand_gender"`
+	BrandLocation   CrossTab `json:"brand_location"`
+	BrandIPLocation CrossTab `json:"brand_ip_location"`
+}
+
+// RunInfo 单次分析的运行信息
+type RunInfo struct {
+	UID            string    `json:"uid"`
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	Limit          int       `json:"limit"`
+	SampleSize     int       `json:"sample_size"`
+	SampleStrategy string    `json:"sample_strategy"`
+}
+
+// Duration 运行时长，尚未结束时按当前时间计算
+func (r RunInfo) Duration() time.Duration {
+	if r.StartedAt.IsZero() {
+		return 0
+	}
+	end := r.FinishedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(r.StartedAt)
 }
 
 // StatisticsData 统计数据（用于导出）
@@ -68,18 +116,92 @@ type UserInfo struct {
 	PhoneType  string `json:"phone_type"`
 	UserName   string `json:"screen_name"`
 	IPLocation string `json:"ip_location"`
+	Source     string `json:"source"` // 设备来源原文，用于映射更新后重新分类
 }
 
 // PhoneBrandMapping 手机品牌映射
 type PhoneBrandMapping map[string]string
 
+// DefaultPhoneMapping 默认的设备来源到品牌映射
+func DefaultPhoneMapping() PhoneBrandMapping {
+	return PhoneBrandMapping{
+		"Huawei":    "华为",
+		"华为":        "华为",
+		"nova":      "华为",
+		"HarmonyOS": "华为",
+		"Xiaomi":    "小米",
+		"小米":        "小米",
+		"OPPO":      "OPPO",
+		"Find":      "OPPO",
+		"Reno":      "OPPO",
+		"Vivo":      "Vivo",
+		"iPhone":    "苹果",
+		"苹果":        "苹果",
+		"Samsung":   "三星",
+		"三星":        "三星",
+		"Meizu":     "魅族",
+		"魅族":        "魅族",
+		"realme":    "真我",
+		"真我":        "真我",
+		"redmi":     "红米",
+		"红米":        "红米",
+		"一加":        "一加",
+		"OnePlus":   "一加",
+		"荣耀":        "荣耀",
+		"Honor":     "荣耀",
+		"honor":     "荣耀",
+		"ZTE":       "中兴",
+		"中兴":        "中兴",
+		"Nubia":     "努比亚",
+		"努比亚":       "努比亚",
+		"IQOO":      "IQOO",
+		"Neo5":      "IQOO",
+		"Android":   "Android设备",
+	}
+}
+
 // GetBrand 获取手机品牌
+//
+// 多个关键字都匹配时取在来源中最先出现的，位置相同取较长的关键字，
+// 例如“OnePlus 12 Android”识别为一加而不是 Android 设备；结果与 map 遍历顺序无关。
 func (p PhoneBrandMapping) GetBrand(phoneType string) string {
 	brand := strings.TrimSpace(strings.ToLower(phoneType))
-	for key, value := range p {
-		if strings.Contains(brand, strings.ToLower(key)) {
-			return value
+	bestKey, bestIndex := "", -1
+	for key := range p {
+		index := strings.Index(brand, strings.ToLower(key))
+		if index < 0 {
+			continue
+		}
+		if bestIndex < 0 || index < bestIndex ||
+			index == bestIndex && (len(key) > len(bestKey) || len(key) == len(bestKey) && key < bestKey) {
+			bestKey, bestIndex = key, index
 		}
 	}
+	if bestIndex >= 0 {
+		return p[bestKey]
+	}
 	return phoneType // 如果找不到映射，返回原始值
 }
+
+// htmlTag 来源文本中可能带有 <a> 链接
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// UnknownDevice 没有任何来源信息时使用的品牌
+const UnknownDevice = "未知设备"
+
+// CleanSource 去掉来源中的 HTML 标签与首尾空白
+func CleanSource(source string) string {
+	return strings.TrimSpace(htmlTag.ReplaceAllString(source, ""))
+}
+
+// ClassifySource 将设备来源原文映射为品牌，抓取与离线重新分类共用同一规则
+func ClassifySource(mapping PhoneBrandMapping, source string) string {
+	source = CleanSource(source)
+	if source == "" {
+		return UnknownDevice
+	}
+	if IsKnownBrand(source) {
+		return source
+	}
+	return mapping.GetBrand(source)
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassifySource(t *testing.T) {
+	mapping := DefaultPhoneMapping()
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"iPhone 15 Pro", "苹果"},
+		{"OnePlus 12 Android", "一加"},
+		{"Redmi K70 Xiaomi", "红米"},
+		{`<a href="https://app.weibo.com/t/feed/x">HUAWEI nova 12</a>`, "华为"},
+		{"苹果", "苹果"},
+		{"微博视频号", "微博视频号"},
+		{"  ", "未知设备"},
+	}
+
+	for _, tt := range tests {
+		// 多次执行以覆盖 map 遍历顺序
+		for i := 0; i < 20; i++ {
+			if got := ClassifySource(mapping, tt.source); got != tt.want {
+				t.Fatalf("ClassifySource(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestLoadPhoneMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapping.json")
+	if err := os.WriteFile(path, []byte(`{"SM-S9180": "三星", "Android": "未知Android"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mapping, err := LoadPhoneMapping(path)
+	if err != nil {
+		t.Fatalf("LoadPhoneMapping() error = %v", err)
+	}
+	if mapping["SM-S9180"] != "三星" || mapping["Android"] != "未知Android" || mapping["iPhone"] != "苹果" {
+		t.Errorf("LoadPhoneMapping() = %v", mapping)
+	}
+
+	if _, err := LoadPhoneMapping(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadPhoneMapping() on missing file should fail")
+	}
+}
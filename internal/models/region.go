@@ -0,0 +1,57 @@
+package models
+
+import "strings"
+
+// 地区分类
+const (
+	RegionDomestic = "domestic" // 国内省级行政区
+	RegionOverseas = "overseas" // 海外国家或地区
+	RegionUnknown  = "unknown"  // 缺失或无法识别
+)
+
+// chinaProvinces 中国地图（echarts china）使用的省级行政区名称
+var chinaProvinces = map[string]bool{
+	"北京": true, "天津": true, "上海": true, "重庆": true,
+	"河北": true, "山西": true, "辽宁": true, "吉林": true, "黑龙江": true,
+	"江苏": true, "浙江": true, "安徽": true, "福建": true, "江西": true, "山东": true,
+	"河南": true, "湖北": true, "湖南": true, "广东": true, "海南": true,
+	"四川": true, "贵州": true, "云南": true, "陕西": true, "甘肃": true, "青海": true,
+	"台湾": true, "内蒙古": true, "广西": true, "西藏": true, "宁夏": true, "新疆": true,
+	"香港": true, "澳门": true,
+}
+
+// provinceSuffixes 省级行政区全称的后缀，按长度从长到短排列
+var provinceSuffixes = []string{
+	"维吾尔自治区", "壮族自治区", "回族自治区", "特别行政区", "自治区", "省", "市",
+}
+
+// ClassifyIPLocation 将IP属地归一化为中国地图使用的省份名，或识别为海外国家/地区
+//
+// 返回归一化后的名称和分类：国内省份去掉“省”“自治区”等后缀及“中国”前缀，
+// 海外取值形如“海外 美国”或“美国”时统一为国家名。
+func ClassifyIPLocation(ipLocation string) (string, string) {
+	name := NormalizeIPLocation(ipLocation)
+	if name == UnknownDimension || name == "其他" {
+		return UnknownDimension, RegionUnknown
+	}
+
+	if fields := strings.Fields(name); len(fields) > 1 && fields[0] == "海外" {
+		return strings.Join(fields[1:], " "), RegionOverseas
+	}
+	if name == "海外" {
+		return UnknownDimension, RegionOverseas
+	}
+
+	province := strings.TrimPrefix(name, "中国")
+	for _, suffix := range provinceSuffixes {
+		if trimmed := strings.TrimSuffix(province, suffix); trimmed != province && chinaProvinces[trimmed] {
+			province = trimmed
+			break
+		}
+	}
+	if chinaProvinces[province] {
+		return province, RegionDomestic
+	}
+
+	return name, RegionOverseas
+}
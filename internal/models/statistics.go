@@ -0,0 +1,161 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// 维度缺失时的统一取值
+const UnknownDimension = "未知"
+
+// knownBrands 已知手机品牌
+var knownBrands = map[string]bool{
+	"华为":        true,
+	"小米":        true,
+	"OPPO":      true,
+	"Vivo":      true,
+	"苹果":        true,
+	"三星":        true,
+	"魅族":        true,
+	"真我":        true,
+	"红米":        true,
+	"一加":        true,
+	"荣耀":        true,
+	"中兴":        true,
+	"努比亚":       true,
+	"IQOO":      true,
+	"未知Android": true,
+}
+
+// IsKnownBrand 检查是否为已知品牌
+func IsKnownBrand(phoneType string) bool {
+	return knownBrands[phoneType]
+}
+
+// CrossTab 交叉统计表：行（品牌）-> 列（维度取值）-> 人数
+type CrossTab map[string]map[string]int
+
+// add 行列计数加一
+func (t CrossTab) add(row, col string) {
+	if t[row] == nil {
+		t[row] = make(map[string]int)
+	}
+	t[row][col]++
+}
+
+// clone 深拷贝交叉统计表
+func (t CrossTab) clone() CrossTab {
+	result := make(CrossTab, len(t))
+	for row, cols := range t {
+		result[row] = make(map[string]int, len(cols))
+		for col, count := range cols {
+			result[row][col] = count
+		}
+	}
+	return result
+}
+
+// NewPhoneStatistics 创建空的统计数据
+func NewPhoneStatistics() *PhoneStatistics {
+	return &PhoneStatistics{
+		BrandCounts:      make(map[string]int),
+		GenderCounts:     make(map[string]int),
+		LocationCounts:   make(map[string]int),
+		IPLocationCounts: make(map[string]int),
+		BrandGender:      make(CrossTab),
+		BrandLocation:    make(CrossTab),
+		BrandIPLocation:  make(CrossTab),
+	}
+}
+
+// Add 将一个用户计入各维度统计
+func (s *PhoneStatistics) Add(user *UserInfo) {
+	brand := user.PhoneType
+	gender := NormalizeGender(user.Gender)
+	location := NormalizeLocation(user.Location)
+	ipLocation := NormalizeIPLocation(user.IPLocation)
+
+	s.BrandCounts[brand]++
+	s.UserCount++
+
+	s.GenderCounts[gender]++
+	s.LocationCounts[location]++
+	s.IPLocationCounts[ipLocation]++
+
+	s.BrandGender.add(brand, gender)
+	s.BrandLocation.add(brand, location)
+	s.BrandIPLocation.add(brand, ipLocation)
+}
+
+// Clone 深拷贝统计数据
+func (s *PhoneStatistics) Clone() *PhoneStatistics {
+	result := &PhoneStatistics{
+		BrandCounts:      cloneCounts(s.BrandCounts),
+		UserCount:        s.UserCount,
+		GenderCounts:     cloneCounts(s.GenderCounts),
+		LocationCounts:   cloneCounts(s.LocationCounts),
+		IPLocationCounts: cloneCounts(s.IPLocationCounts),
+		BrandGender:      s.BrandGender.clone(),
+		BrandLocation:    s.BrandLocation.clone(),
+		BrandIPLocation:  s.BrandIPLocation.clone(),
+	}
+	return result
+}
+
+// BrandData 返回按数量降序排列的品牌统计
+func (s *PhoneStatistics) BrandData() []StatisticsData {
+	return SortedCounts(s.BrandCounts)
+}
+
+// SortedCounts 将计数表转换为按数量降序、名称升序排列的统计数据
+func SortedCounts(counts map[string]int) []StatisticsData {
+	result := make([]StatisticsData, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, StatisticsData{PhoneType: name, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].PhoneType < result[j].PhoneType
+	})
+	return result
+}
+
+// NormalizeGender 将微博性别代码转换为中文
+func NormalizeGender(gender string) string {
+	switch strings.TrimSpace(gender) {
+	case "m":
+		return "男"
+	case "f":
+		return "女"
+	default:
+		return UnknownDimension
+	}
+}
+
+// NormalizeLocation 取资料地区的省级部分，如“香港 其他”归为“香港”
+func NormalizeLocation(location string) string {
+	fields := strings.Fields(location)
+	if len(fields) == 0 {
+		return UnknownDimension
+	}
+	return fields[0]
+}
+
+// NormalizeIPLocation 去掉“IP属地：”前缀
+func NormalizeIPLocation(ipLocation string) string {
+	ipLocation = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(ipLocation), "IP属地："))
+	if ipLocation == "" {
+		return UnknownDimension
+	}
+	return ipLocation
+}
+
+func cloneCounts(counts map[string]int) map[string]int {
+	result := make(map[string]int, len(counts))
+	for k, v := range counts {
+		result[k] = v
+	}
+	return result
+}
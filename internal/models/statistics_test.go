@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPhoneStatistics_Add(t *testing.T) {
+	stats := NewPhoneStatistics()
+	stats.Add(&UserInfo{PhoneType: "苹果", Gender: "f", Location: "香港 其他", IPLocation: "IP属地：广东"})
+	stats.Add(&UserInfo{PhoneType: "苹果", Gender: "m", Location: "广东 深圳", IPLocation: "广东"})
+	stats.Add(&UserInfo{PhoneType: "华为", Gender: "", Location: "", IPLocation: ""})
+
+	if stats.UserCount != 3 || stats.BrandCounts["苹果"] != 2 {
+		t.Fatalf("brand counts = %v, user count = %d", stats.BrandCounts, stats.UserCount)
+	}
+	if stats.GenderCounts["女"] != 1 || stats.GenderCounts["男"] != 1 || stats.GenderCounts[UnknownDimension] != 1 {
+		t.Errorf("GenderCounts = %v", stats.GenderCounts)
+	}
+	if stats.LocationCounts["香港"] != 1 || stats.LocationCounts["广东"] != 1 {
+		t.Errorf("LocationCounts = %v", stats.LocationCounts)
+	}
+	if stats.BrandIPLocation["苹果"]["广东"] != 2 || stats.BrandIPLocation["华为"][UnknownDimension] != 1 {
+		t.Errorf("BrandIPLocation = %v", stats.BrandIPLocation)
+	}
+	if stats.BrandGender["苹果"]["女"] != 1 {
+		t.Errorf("BrandGender = %v", stats.BrandGender)
+	}
+
+	// 副本与原数据互不影响
+	clone := stats.Clone()
+	clone.Add(&UserInfo{PhoneType: "苹果", IPLocation: "广东"})
+	if stats.BrandIPLocation["苹果"]["广东"] != 2 || clone.BrandIPLocation["苹果"]["广东"] != 3 {
+		t.Errorf("Clone shares state with original")
+	}
+}
+
+func TestClassifyIPLocation(t *testing.T) {
+	tests := []struct {
+		raw      string
+		wantName string
+		wantKind string
+	}{
+		{"IP属地：广东", "广东", RegionDomestic},
+		{"广东省", "广东", RegionDomestic},
+		{"新疆维吾尔自治区", "新疆", RegionDomestic},
+		{"中国香港", "香港", RegionDomestic},
+		{"海外 美国", "美国", RegionOverseas},
+		{"日本", "日本", RegionOverseas},
+		{"", UnknownDimension, RegionUnknown},
+		{"其他", UnknownDimension, RegionUnknown},
+	}
+
+	for _, tt := range tests {
+		name, kind := ClassifyIPLocation(tt.raw)
+		if name != tt.wantName || kind != tt.wantKind {
+			t.Errorf("ClassifyIPLocation(%q) = %q, %q, want %q, %q", tt.raw, name, kind, tt.wantName, tt.wantKind)
+		}
+	}
+}
+
+func TestCompareBrands(t *testing.T) {
+	before := &PhoneStatistics{BrandCounts: map[string]int{"苹果": 3, "华为": 1}, UserCount: 4}
+	after := &PhoneStatistics{BrandCounts: map[string]int{"苹果": 1, "小米": 1}, UserCount: 2}
+
+	got := CompareBrands(before, after)
+	if len(got) != 3 {
+		t.Fatalf("CompareBrands() = %+v, want 3 brands", got)
+	}
+	if got[0].Brand != "苹果" || got[0].Before != 3 || got[0].After != 1 || got[0].Change() != -25 {
+		t.Errorf("got[0] = %+v, change %.1f", got[0], got[0].Change())
+	}
+	if got[2].Brand != "华为" || got[2].After != 0 || got[2].AfterShare != 0 {
+		t.Errorf("got[2] = %+v", got[2])
+	}
+}
+
+func TestTrend(t *testing.T) {
+	stats := func(counts map[string]int) *PhoneStatistics {
+		s := NewPhoneStatistics()
+		for brand, n := range counts {
+			for i := 0; i < n; i++ {
+				s.Add(&UserInfo{PhoneType: brand})
+			}
+		}
+		return s
+	}
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []TrendPoint{
+		{Time: jan.AddDate(0, 1, 0), Stats: stats(map[string]int{"苹果": 1, "华为": 3})},
+		{Time: jan, Stats: stats(map[string]int{"苹果": 3, "小米": 1})},
+	}
+
+	trends := Trend(points)
+	if !points[0].Time.Equal(jan) {
+		t.Errorf("points not sorted by time")
+	}
+	if len(trends) != 3 || trends[0].Brand != "华为" || trends[1].Brand != "苹果" || trends[2].Brand != "小米" {
+		t.Fatalf("trend order = %+v", trends)
+	}
+	if fmt.Sprint(trends[1].Counts) != "[3 1]" || fmt.Sprint(trends[1].Shares) != "[75 25]" {
+		t.Errorf("苹果 trend = %+v", trends[1])
+	}
+	if fmt.Sprint(trends[2].Counts) != "[1 0]" {
+		t.Errorf("missing brand should count as 0: %+v", trends[2])
+	}
+}
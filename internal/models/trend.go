@@ -0,0 +1,53 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// TrendPoint 一次运行的时间与统计
+type TrendPoint struct {
+	Time  time.Time
+	Stats *PhoneStatistics
+}
+
+// BrandTrend 一个品牌在各次运行中的人数与占比（百分比），顺序与运行顺序一致
+type BrandTrend struct {
+	Brand  string    `json:"brand"`
+	Counts []int     `json:"counts"`
+	Shares []float64 `json:"shares"`
+}
+
+// Trend 将运行按时间排序并计算各品牌的占比变化，品牌按最近一次运行的人数从多到少排列
+func Trend(points []TrendPoint) []BrandTrend {
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+
+	brands := make(map[string]bool)
+	for _, point := range points {
+		for brand := range point.Stats.BrandCounts {
+			brands[brand] = true
+		}
+	}
+
+	trends := make([]BrandTrend, 0, len(brands))
+	for brand := range brands {
+		trend := BrandTrend{Brand: brand}
+		for _, point := range points {
+			count := point.Stats.BrandCounts[brand]
+			trend.Counts = append(trend.Counts, count)
+			trend.Shares = append(trend.Shares, share(count, point.Stats.UserCount))
+		}
+		trends = append(trends, trend)
+	}
+
+	// 依次比较最近的运行，并列时按品牌名排列
+	sort.Slice(trends, func(i, j int) bool {
+		for k := len(points) - 1; k >= 0; k-- {
+			if trends[i].Counts[k] != trends[j].Counts[k] {
+				return trends[i].Counts[k] > trends[j].Counts[k]
+			}
+		}
+		return trends[i].Brand < trends[j].Brand
+	})
+	return trends
+}
@@ -0,0 +1,45 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	from := time.Date(2025, 1, 15, 10, 30, 0, 0, time.Local) // 周三
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 1, 15, 10, 31, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2025, 1, 15, 10, 45, 0, 0, time.Local)},
+		{"0 3 * * *", time.Date(2025, 1, 16, 3, 0, 0, 0, time.Local)},
+		{"@monthly", time.Date(2025, 2, 1, 0, 0, 0, 0, time.Local)},
+		{"0 9 1,15 * *", time.Date(2025, 2, 1, 9, 0, 0, 0, time.Local)},
+		{"0 9 * * 1-5", time.Date(2025, 1, 16, 9, 0, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2025, 1, 19, 0, 0, 0, 0, time.Local)},
+		// 日与星期都指定时满足其一即可
+		{"0 0 20 * 5", time.Date(2025, 1, 17, 0, 0, 0, 0, time.Local)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.Local)},
+		{"30 10 15 1 *", time.Date(2026, 1, 15, 10, 30, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
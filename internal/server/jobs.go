@@ -0,0 +1,96 @@
+package server
+
+import (
+	"comment_phone_analyse/internal/jobs"
+	"comment_phone_analyse/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+)
+
+// SetJobs 启用任务接口，需在 Handler 之前调用
+func (s *Server) SetJobs(manager *jobs.Manager) {
+	s.jobs = manager
+}
+
+// registerJobs 注册任务接口
+func (s *Server) registerJobs(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/jobs", s.handleSubmitJob)
+	mux.HandleFunc("GET /api/jobs", s.handleListJobs)
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("DELETE /api/jobs/{id}", s.handleCancelJob)
+	mux.HandleFunc("GET /api/jobs/{id}/files/", s.handleJobFiles)
+}
+
+// handleSubmitJob 提交任务，返回 202 与排队中的任务
+func (s *Server) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	var spec jobs.Spec
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		writeError(w, http.StatusBadRequest, "请求体不是有效的任务参数: "+err.Error())
+		return
+	}
+
+	job, err := s.jobs.Submit(spec)
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, job)
+}
+
+// handleListJobs 列出任务，可用 status 参数筛选
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.jobs.List(jobs.Status(r.URL.Query().Get("status"))))
+}
+
+// handleGetJob 返回任务状态，结束后包含结果
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobs.Get(r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	writeJSON(w, job)
+}
+
+// handleCancelJob 取消排队或执行中的任务
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobs.Cancel(r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	writeJSON(w, job)
+}
+
+// handleJobFiles 提供任务目录中的图表与用户记录
+func (s *Server) handleJobFiles(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobs.Get(r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	prefix := "/api/jobs/" + job.ID + "/files/"
+	http.StripPrefix(prefix, http.FileServer(http.Dir(filepath.Clean(job.OutputDir)))).ServeHTTP(w, r)
+}
+
+// writeJobError 将任务错误转换为 HTTP 状态码
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, jobs.ErrFinished):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, jobs.ErrQueueFull):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	case utils.ExitCode(err) == utils.ExitConfig:
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"comment_phone_analyse/analysis"
+	"comment_phone_analyse/config"
+	"comment_phone_analyse/internal/jobs"
+	"comment_phone_analyse/internal/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Jobs(t *testing.T) {
+	// runner 写入一个文件后等待取消
+	run := func(ctx context.Context, cfg *config.Config) (*analysis.Result, error) {
+		os.WriteFile(filepath.Join(cfg.OutputDir, "run.json"), []byte(`{"uid":"`+cfg.UID+`"}`), 0644)
+		<-ctx.Done()
+		return &analysis.Result{Run: models.RunInfo{UID: cfg.UID}}, ctx.Err()
+	}
+	manager, err := jobs.NewManager(&config.Config{Cookie: "SUB=x"}, t.TempDir(), run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); manager.Wait() }()
+	manager.Start(ctx, 1)
+
+	live := New(t.TempDir(), nil)
+	live.SetJobs(manager)
+	ts := httptest.NewServer(live.Handler())
+	defer ts.Close()
+
+	for body, want := range map[string]int{
+		`{"limit":5}`:            http.StatusBadRequest, // 缺少 uid
+		`{"uid":"42","foo":1}`:   http.StatusBadRequest, // 未知字段
+		`{"uid":"42","limit":5}`: http.StatusAccepted,
+	} {
+		resp, err := http.Post(ts.URL+"/api/jobs", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("POST %s status = %d, want %d", body, resp.StatusCode, want)
+		}
+		if want == http.StatusAccepted && !strings.HasPrefix(resp.Header.Get("Location"), "/api/jobs/") {
+			t.Errorf("Location = %q", resp.Header.Get("Location"))
+		}
+	}
+
+	var list []jobs.Job
+	getJSON(t, ts.URL+"/api/jobs", &list)
+	if len(list) != 1 || list[0].Spec.UID != "42" {
+		t.Fatalf("/api/jobs = %+v", list)
+	}
+	id := list[0].ID
+
+	var job jobs.Job
+	for deadline := time.Now().Add(5 * time.Second); job.Status != jobs.StatusRunning && time.Now().Before(deadline); {
+		getJSON(t, ts.URL+"/api/jobs/"+id, &job)
+	}
+	if job.Status != jobs.StatusRunning {
+		t.Fatalf("job status = %s, want running", job.Status)
+	}
+	if status := getJSON(t, ts.URL+"/api/jobs/"+id+"/files/run.json", nil); status != http.StatusOK {
+		t.Errorf("job file status = %d, want 200", status)
+	}
+	if status := getJSON(t, ts.URL+"/api/jobs/missing", nil); status != http.StatusNotFound {
+		t.Errorf("missing job status = %d, want 404", status)
+	}
+
+	request, _ := http.NewRequest(http.MethodDelete, ts.URL+"/api/jobs/"+id, nil)
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("DELETE status = %d, want 200", resp.StatusCode)
+	}
+	for deadline := time.Now().Add(5 * time.Second); job.Status != jobs.StatusCanceled && time.Now().Before(deadline); {
+		getJSON(t, ts.URL+"/api/jobs/"+id, &job)
+	}
+	if job.Status != jobs.StatusCanceled || job.Result == nil {
+		t.Errorf("canceled job = %+v", job)
+	}
+
+	resp, _ = http.DefaultClient.Do(request)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("DELETE finished job status = %d, want 409", resp.StatusCode)
+	}
+}
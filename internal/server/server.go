@@ -0,0 +1,371 @@
+// Package server 提供本地仪表盘：实时统计页面、SSE 推送与 JSON 接口
+package server
+
+import (
+	"comment_phone_analyse/analysis"
+	"comment_phone_analyse/export"
+	"comment_phone_analyse/internal/jobs"
+	"comment_phone_analyse/internal/storage"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRecentUsers 保留的最近处理用户数
+const maxRecentUsers = 200
+
+// keepAliveInterval SSE 连接的保活间隔，避免代理断开空闲连接
+const keepAliveInterval = 15 * time.Second
+
+//go:embed page.html
+var page []byte
+
+// Source 进行中的分析，analysis.Analyzer 实现了该接口
+type Source interface {
+	Result() *analysis.Result
+	Progress() analysis.Progress
+}
+
+// Server 本地仪表盘服务
+type Server struct {
+	outputDir string
+	store     *storage.Store // 可选，用于列出数据库中的运行
+	jobs      *jobs.Manager  // 可选，设置后提供任务接口
+
+	mutex       sync.RWMutex
+	source      Source
+	running     bool
+	users       []analysis.User // 最近处理的用户，按时间顺序
+	subscribers map[chan event]struct{}
+}
+
+// event 推送给浏览器的 SSE 事件
+type event struct {
+	name string
+	data []byte
+}
+
+// New 创建仪表盘服务，outputDir 下的文件通过 /files/ 提供，store 可为 nil
+func New(outputDir string, store *storage.Store) *Server {
+	return &Server{
+		outputDir:   outputDir,
+		store:       store,
+		subscribers: make(map[chan event]struct{}),
+	}
+}
+
+// Watch 关联进行中的分析，之后 /api/stats 返回其当前结果
+func (s *Server) Watch(source Source) {
+	s.mutex.Lock()
+	s.source = source
+	s.running = true
+	s.users = nil
+	s.mutex.Unlock()
+	s.publishStats()
+}
+
+// Finish 标记分析结束并推送最终结果
+func (s *Server) Finish() {
+	s.mutex.Lock()
+	s.running = false
+	s.mutex.Unlock()
+	s.publishStats()
+}
+
+// AddUser 记录一个刚处理完的用户并推送更新，可直接作为 analysis.WithUserHandler 的回调
+func (s *Server) AddUser(user *analysis.User) {
+	s.mutex.Lock()
+	s.users = append(s.users, *user)
+	if len(s.users) > maxRecentUsers {
+		s.users = s.users[len(s.users)-maxRecentUsers:]
+	}
+	s.mutex.Unlock()
+
+	if data, err := json.Marshal(user); err == nil {
+		s.broadcast(event{name: "user", data: data})
+	}
+	s.publishStats()
+}
+
+// Handler 返回全部路由
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handlePage)
+	mux.HandleFunc("GET /api/stats", s.handleStats)
+	mux.HandleFunc("GET /api/users", s.handleUsers)
+	mux.HandleFunc("GET /api/runs", s.handleRuns)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	mux.HandleFunc("GET /assets/{name...}", handleAsset)
+	mux.Handle("GET /files/", http.StripPrefix("/files/", http.FileServer(http.Dir(s.outputDir))))
+	if s.jobs != nil {
+		s.registerJobs(mux)
+	}
+	return mux
+}
+
+// handlePage 返回实时统计页面
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+// handleAsset 返回内嵌的 echarts 脚本
+func handleAsset(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean(r.PathValue("name"))
+	content, err := export.Asset(name)
+	if err != nil {
+		slog.Error("缺少内嵌脚本，见 export/assets/README.md", "name", name, "err", err)
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Write(content)
+}
+
+// statsResponse /api/stats 的响应
+type statsResponse struct {
+	Running    bool                  `json:"running"`
+	Run        analysis.RunInfo      `json:"run"`
+	Progress   progressResponse      `json:"progress"`
+	Statistics *analysis.Statistics  `json:"statistics"`
+	Known      []analysis.BrandCount `json:"known"`
+	Unknown    []analysis.BrandCount `json:"unknown"`
+	OutputDir  string                `json:"output_dir"`
+}
+
+// progressResponse 进度，时间以秒为单位
+type progressResponse struct {
+	Done              int      `json:"done"`
+	Limit             int      `json:"limit"`
+	Skipped           int      `json:"skipped"`
+	Requests          int64    `json:"requests"`
+	RequestErrors     int64    `json:"request_errors"`
+	RequestsPerMinute float64  `json:"requests_per_minute"`
+	ElapsedSeconds    float64  `json:"elapsed_seconds"`
+	ETASeconds        *float64 `json:"eta_seconds"` // 无法估算时为 null
+}
+
+// stats 生成当前统计，没有关联分析时返回 false
+func (s *Server) stats() (*statsResponse, bool) {
+	s.mutex.RLock()
+	source, running := s.source, s.running
+	s.mutex.RUnlock()
+	if source == nil {
+		return nil, false
+	}
+
+	result := source.Result()
+	progress := source.Progress()
+	response := &statsResponse{
+		Running:    running,
+		Run:        result.Run,
+		Statistics: result.Statistics,
+		Known:      result.Known,
+		Unknown:    result.Unknown,
+		OutputDir:  result.OutputDir,
+		Progress: progressResponse{
+			Done:              progress.Done,
+			Limit:             progress.Limit,
+			Skipped:           progress.Skipped,
+			Requests:          progress.Requests,
+			RequestErrors:     progress.RequestErrors,
+			RequestsPerMinute: progress.RequestsPerMinute(),
+			ElapsedSeconds:    progress.Elapsed.Seconds(),
+		},
+	}
+	if eta, ok := progress.ETA(); ok {
+		seconds := eta.Seconds()
+		response.Progress.ETASeconds = &seconds
+	}
+	return response, true
+}
+
+// handleStats 返回当前统计
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	response, ok := s.stats()
+	if !ok {
+		writeError(w, http.StatusNotFound, "没有进行中的分析")
+		return
+	}
+	writeJSON(w, response)
+}
+
+// handleUsers 返回最近处理的用户，最新的在前，limit 默认 50
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit 必须是正整数")
+			return
+		}
+		limit = min(n, maxRecentUsers)
+	}
+
+	s.mutex.RLock()
+	users := make([]analysis.User, 0, min(limit, len(s.users)))
+	for i := len(s.users) - 1; i >= 0 && len(users) < limit; i-- {
+		users = append(users, s.users[i])
+	}
+	s.mutex.RUnlock()
+	writeJSON(w, users)
+}
+
+// runResponse /api/runs 中的一次运行
+type runResponse struct {
+	analysis.RunInfo
+	ID  int64  `json:"id,omitempty"`  // 数据库中的运行ID
+	Dir string `json:"dir,omitempty"` // 输出目录中的运行，相对 /files/ 的路径
+}
+
+// handleRuns 列出输出目录与数据库中的运行，按开始时间倒序
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	var runs []runResponse
+
+	dirs, _ := filepath.Glob(filepath.Join(s.outputDir, "*", export.RunInfoFile))
+	for _, file := range dirs {
+		dir := filepath.Dir(file)
+		run, err := export.ReadRunInfo(dir)
+		if err != nil {
+			continue
+		}
+		runs = append(runs, runResponse{RunInfo: run, Dir: filepath.Base(dir)})
+	}
+
+	if s.store != nil {
+		stored, err := s.store.Runs(r.URL.Query().Get("uid"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, run := range stored {
+			runs = append(runs, runResponse{RunInfo: run.Info, ID: run.ID})
+		}
+	}
+
+	if uid := r.URL.Query().Get("uid"); uid != "" {
+		filtered := runs[:0]
+		for _, run := range runs {
+			if run.UID == uid {
+				filtered = append(filtered, run)
+			}
+		}
+		runs = filtered
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	if runs == nil {
+		runs = []runResponse{}
+	}
+	writeJSON(w, runs)
+}
+
+// handleEvents 以 SSE 推送统计（stats 事件）与新处理的用户（user 事件）
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "不支持流式响应")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	events := s.subscribe()
+	defer s.unsubscribe(events)
+
+	// 连接后立即推送一次当前统计
+	if response, ok := s.stats(); ok {
+		if data, err := json.Marshal(response); err == nil {
+			writeEvent(w, event{name: "stats", data: data})
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-events:
+			writeEvent(w, e)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent 写入一条 SSE 事件
+func writeEvent(w http.ResponseWriter, e event) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, e.data)
+}
+
+// subscribe 注册 SSE 订阅者
+func (s *Server) subscribe() chan event {
+	events := make(chan event, 16)
+	s.mutex.Lock()
+	s.subscribers[events] = struct{}{}
+	s.mutex.Unlock()
+	return events
+}
+
+// unsubscribe 移除 SSE 订阅者
+func (s *Server) unsubscribe(events chan event) {
+	s.mutex.Lock()
+	delete(s.subscribers, events)
+	s.mutex.Unlock()
+}
+
+// publishStats 推送当前统计
+func (s *Server) publishStats() {
+	response, ok := s.stats()
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	s.broadcast(event{name: "stats", data: data})
+}
+
+// broadcast 向所有订阅者推送事件，跟不上的订阅者丢弃该事件
+func (s *Server) broadcast(e event) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for events := range s.subscribers {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+// writeJSON 写入 JSON 响应
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		slog.Error("写入响应失败", "err", err)
+	}
+}
+
+// writeError 写入 JSON 错误响应
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
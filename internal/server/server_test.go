@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bufio"
+	"comment_phone_analyse/analysis"
+	"comment_phone_analyse/export"
+	"comment_phone_analyse/internal/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSource 固定结果的分析
+type fakeSource struct {
+	stats *models.PhoneStatistics
+}
+
+func (f *fakeSource) Result() *analysis.Result {
+	return &analysis.Result{
+		Run:        models.RunInfo{UID: "42", Limit: 10},
+		Statistics: f.stats.Clone(),
+		Known:      models.SortedCounts(f.stats.BrandCounts),
+	}
+}
+
+func (f *fakeSource) Progress() analysis.Progress {
+	return analysis.Progress{Done: f.stats.UserCount, Limit: 10, Elapsed: time.Minute}
+}
+
+func getJSON(t *testing.T, url string, value any) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if value != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestServer_API(t *testing.T) {
+	dir := t.TempDir()
+	runDir := filepath.Join(dir, "42")
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := export.WriteRunInfo(runDir, models.RunInfo{UID: "42", SampleSize: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	live := New(dir, nil)
+	ts := httptest.NewServer(live.Handler())
+	defer ts.Close()
+
+	if status := getJSON(t, ts.URL+"/api/stats", nil); status != http.StatusNotFound {
+		t.Errorf("/api/stats before Watch status = %d, want 404", status)
+	}
+
+	source := &fakeSource{stats: models.NewPhoneStatistics()}
+	live.Watch(source)
+	for _, user := range []models.UserInfo{{Id: "1", PhoneType: "苹果"}, {Id: "2", PhoneType: "华为"}, {Id: "3", PhoneType: "苹果"}} {
+		source.stats.Add(&user)
+		live.AddUser(&user)
+	}
+
+	var stats statsResponse
+	getJSON(t, ts.URL+"/api/stats", &stats)
+	if !stats.Running || stats.Statistics.UserCount != 3 || stats.Progress.Done != 3 || stats.Progress.ETASeconds == nil {
+		t.Errorf("/api/stats = %+v", stats)
+	}
+
+	var users []models.UserInfo
+	getJSON(t, ts.URL+"/api/users?limit=2", &users)
+	if len(users) != 2 || users[0].Id != "3" || users[1].Id != "2" {
+		t.Errorf("/api/users = %+v, want newest two", users)
+	}
+	if status := getJSON(t, ts.URL+"/api/users?limit=x", nil); status != http.StatusBadRequest {
+		t.Errorf("/api/users?limit=x status = %d, want 400", status)
+	}
+
+	var runs []runResponse
+	getJSON(t, ts.URL+"/api/runs", &runs)
+	if len(runs) != 1 || runs[0].UID != "42" || runs[0].Dir != "42" {
+		t.Errorf("/api/runs = %+v", runs)
+	}
+
+	live.Finish()
+	getJSON(t, ts.URL+"/api/stats", &stats)
+	if stats.Running {
+		t.Error("/api/stats still running after Finish")
+	}
+}
+
+func TestServer_Events(t *testing.T) {
+	live := New(t.TempDir(), nil)
+	source := &fakeSource{stats: models.NewPhoneStatistics()}
+	live.Watch(source)
+	ts := httptest.NewServer(live.Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// 连接后先收到当前统计，新增用户时依次收到 user 与 stats 事件
+	var names []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && len(names) < 3 {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			names = append(names, name)
+			if len(names) == 1 {
+				user := models.UserInfo{Id: "1", PhoneType: "苹果"}
+				source.stats.Add(&user)
+				live.AddUser(&user)
+			}
+		}
+	}
+	if strings.Join(names, ",") != "stats,user,stats" {
+		t.Errorf("events = %v, want [stats user stats]", names)
+	}
+}
+
+func TestServer_Assets(t *testing.T) {
+	ts := httptest.NewServer(New(t.TempDir(), nil).Handler())
+	defer ts.Close()
+
+	// 页面从内嵌脚本加载 echarts，不依赖 CDN
+	for _, name := range []string{"echarts.min.js", "maps/china.js"} {
+		resp, err := http.Get(ts.URL + "/assets/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET /assets/%s status = %d, want 200", name, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/javascript") {
+			t.Errorf("GET /assets/%s content type = %q, want text/javascript", name, got)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/assets/missing.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /assets/missing.js status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -2,8 +2,13 @@ package services
 
 import (
 	"comment_phone_analyse/config"
+	"comment_phone_analyse/export"
+	"comment_phone_analyse/internal/client"
 	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/storage"
+	"context"
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -13,70 +18,206 @@ import (
 	"time"
 )
 
+// AnalyzerOptions 分析服务的选项
+type AnalyzerOptions struct {
+	Crawl         CrawlOptions
+	OutputDir     string                 // 输出根目录，用户记录写入 <OutputDir>/<UID>
+	RecordFormats []string               // 用户记录导出格式，取值见 config.RecordFormat*
+	StoragePath   string                 // SQLite 数据库路径，为空时不写入数据库
+	OnUser        func(*models.UserInfo) // 每处理完一个用户时调用，可为 nil
+	Logger        *slog.Logger           // 为 nil 时使用 slog.Default()
+}
+
 // AnalyzerService 分析服务
 type AnalyzerService struct {
 	weiboService   *WeiboService
+	options        AnalyzerOptions
 	statistics     *models.PhoneStatistics
-	processedUsers map[string]bool // 存储已处理过的用户ID，避免重复处理
-	statsFile      *os.File        // 实时统计数据文件
+	processedUsers map[string]bool       // 存储已处理过的用户ID，避免重复处理
+	outputDir      string                // 用户专属输出目录
+	recordWriters  []export.RecordWriter // 实时写入用户记录
+	startedAt      time.Time             // 本次分析开始时间
+	finishedAt     time.Time             // 本次分析结束时间，未结束时为零值
+	store          *storage.Store        // 可选的 SQLite 存储，未配置时为 nil
+	runID          int64                 // 当前运行在数据库中的ID
+	skippedUsers   int                   // 因请求失败跳过的用户数
+	logger         *slog.Logger
 	mutex          sync.RWMutex
 }
 
 // NewAnalyzerService 创建分析服务
-func NewAnalyzerService(weiboService *WeiboService) *AnalyzerService {
-	cfg := config.GetGlobalConfig()
-
+func NewAnalyzerService(weiboService *WeiboService, opts AnalyzerOptions) *AnalyzerService {
 	// 创建用户专属的输出目录
-	outputDir := cfg.OutputDir
-	uid := cfg.UID
+	outputDir := opts.OutputDir
+	uid := opts.Crawl.UID
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
 
 	userOutputDir := filepath.Join(outputDir, uid)
 	if err := os.MkdirAll(userOutputDir, 0755); err != nil {
-		fmt.Printf("创建用户输出目录失败: %v\n", err)
+		logger.Error("创建用户输出目录失败", "err", err)
 		userOutputDir = outputDir // 降级到基础目录
 	}
 
-	// 创建统计数据文件
-	statsFilePath := filepath.Join(userOutputDir, "stats.txt")
-	statsFile, err := os.OpenFile(statsFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		fmt.Printf("创建统计数据文件失败: %v\n", err)
-		statsFile = nil
+	analyzer := &AnalyzerService{
+		weiboService:   weiboService,
+		options:        opts,
+		statistics:     models.NewPhoneStatistics(),
+		processedUsers: make(map[string]bool),
+		outputDir:      userOutputDir,
+		logger:         logger,
+	}
+	analyzer.openRecordWriters(opts.RecordFormats)
+	if weiboService.schema != nil {
+		weiboService.schema.setDumpDir(userOutputDir)
 	}
 
-	return &AnalyzerService{
-		weiboService: weiboService,
-		statistics: &models.PhoneStatistics{
-			BrandCounts: make(map[string]int),
-			UserCount:   0,
-		},
-		processedUsers: make(map[string]bool),
-		statsFile:      statsFile,
+	if opts.StoragePath != "" {
+		store, err := storage.Open(opts.StoragePath)
+		if err != nil {
+			logger.Error("打开数据库失败，本次运行不写入数据库", "path", opts.StoragePath, "err", err)
+		} else {
+			analyzer.store = store
+			weiboService.SetObserver(analyzer)
+		}
 	}
+	return analyzer
 }
 
-// AnalyzeUserPhones 分析用户手机品牌分布
-func (a *AnalyzerService) AnalyzeUserPhones() *models.PhoneStatistics {
-	config := config.GetGlobalConfig()
-	uid := config.UID
-	limit := config.Limit
-	fmt.Printf("开始分析用户 %s 的手机品牌分布，限制 %d 个用户\n", uid, limit)
+// openRecordWriters 按配置的格式创建用户记录文件，已存在的文件会被清空
+func (a *AnalyzerService) openRecordWriters(formats []string) {
+	for _, format := range formats {
+		var writer export.RecordWriter
+		var err error
+		switch format {
+		case config.RecordFormatCSV:
+			writer, err = export.NewCSVRecordWriter(filepath.Join(a.outputDir, export.CSVRecordsFile))
+		case config.RecordFormatJSONL:
+			writer, err = export.NewJSONLRecordWriter(filepath.Join(a.outputDir, export.JSONLRecordsFile))
+		}
+		if err != nil {
+			a.logger.Error("创建用户记录文件失败", "format", format, "err", err)
+			continue
+		}
+		a.recordWriters = append(a.recordWriters, writer)
+	}
+}
+
+// closeRecordWriters 关闭所有用户记录文件
+func (a *AnalyzerService) closeRecordWriters() error {
+	var firstErr error
+	for _, writer := range a.recordWriters {
+		if err := writer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	a.recordWriters = nil
+	return firstErr
+}
+
+// AnalyzeUserPhones 分析用户手机品牌分布，ctx 取消时停止抓取并返回已处理部分的统计
+//
+// 响应结构异常达到阈值时同样停止抓取，错误由 Err 返回。
+func (a *AnalyzerService) AnalyzeUserPhones(ctx context.Context) *models.PhoneStatistics {
+	a.logger.Info("开始分析手机品牌分布", "limit", a.options.Crawl.Limit)
 
 	// 重置统计
 	a.resetStatistics()
+	a.weiboService.ResetRequestStats()
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+	if a.weiboService.schema != nil {
+		a.weiboService.schema.reset(abort)
+	}
+	a.setRunTimes(time.Now(), time.Time{})
+	a.startStoredRun()
 
 	// 定义用户处理回调
 	userCallback := func(users []models.CommentUser) {
-		a.processUsers(users)
+		a.processUsers(ctx, users)
 	}
 
 	// 获取并处理用户
-	a.weiboService.GetUserBlogsAndComments(userCallback)
+	a.weiboService.GetUserBlogsAndComments(ctx, a.options.Crawl, userCallback)
+	a.setRunTimes(a.startedAt, time.Now())
+	a.finishStoredRun()
 
-	fmt.Printf("分析完成，共处理 %d 个用户\n", a.statistics.UserCount)
+	a.logger.Info("分析完成", "users", a.statistics.UserCount, "skipped", a.skippedUsers)
 	return a.statistics
 }
 
+// setRunTimes 记录运行起止时间
+func (a *AnalyzerService) setRunTimes(startedAt, finishedAt time.Time) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.startedAt = startedAt
+	a.finishedAt = finishedAt
+}
+
+// startStoredRun 在数据库中登记本次运行
+func (a *AnalyzerService) startStoredRun() {
+	if a.store == nil {
+		return
+	}
+	runID, err := a.store.StartRun(a.GetRunInfo())
+	if err != nil {
+		a.logger.Error("写入运行记录失败", "err", err)
+	}
+	a.mutex.Lock()
+	a.runID = runID
+	a.mutex.Unlock()
+}
+
+// finishStoredRun 在数据库中记录本次运行的结束时间与样本量
+func (a *AnalyzerService) finishStoredRun() {
+	if a.store == nil || a.currentRunID() == 0 {
+		return
+	}
+	if err := a.store.FinishRun(a.currentRunID(), a.GetRunInfo()); err != nil {
+		a.logger.Error("更新运行记录失败", "run", a.currentRunID(), "err", err)
+	}
+}
+
+// currentRunID 获取当前运行在数据库中的ID，未写入数据库时为 0
+func (a *AnalyzerService) currentRunID() int64 {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.runID
+}
+
+// OnComments 实现 CrawlObserver，将采样的博客与评论用户写入数据库
+func (a *AnalyzerService) OnComments(blog models.Blog, users []models.CommentUser) {
+	runID := a.currentRunID()
+	if a.store == nil || runID == 0 {
+		return
+	}
+	if err := a.store.SavePost(runID, blog); err != nil {
+		a.logger.Error("写入博客失败", "post", blog.MblogID, "err", err)
+		return
+	}
+	if err := a.store.SaveComments(runID, blog, users); err != nil {
+		a.logger.Error("写入评论失败", "post", blog.MblogID, "err", err)
+	}
+}
+
+// GetRunInfo 获取本次分析的运行信息
+func (a *AnalyzerService) GetRunInfo() models.RunInfo {
+	cfg := a.options.Crawl
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return models.RunInfo{
+		UID:            cfg.UID,
+		StartedAt:      a.startedAt,
+		FinishedAt:     a.finishedAt,
+		Limit:          cfg.Limit,
+		SampleSize:     a.statistics.UserCount,
+		SampleStrategy: cfg.SampleStrategy,
+	}
+}
+
 // isUserProcessed 检查用户是否已处理
 func (a *AnalyzerService) isUserProcessed(userID string) bool {
 	a.mutex.RLock()
@@ -92,28 +233,44 @@ func (a *AnalyzerService) markUserAsProcessed(userID string) {
 }
 
 // processUsers 处理用户列表
-func (a *AnalyzerService) processUsers(users []models.CommentUser) {
-	cfg := config.GetGlobalConfig()
+func (a *AnalyzerService) processUsers(ctx context.Context, users []models.CommentUser) {
+	cfg := a.options.Crawl
 	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
 		// 检查用户是否已处理过（全局去重）
 		if a.isUserProcessed(user.ID) {
 			continue
 		}
 
-		userInfo, err := a.weiboService.GetUserInfo(user.ID)
+		userInfo, err := a.weiboService.GetUserInfo(ctx, user.ID)
 		if err != nil {
-			fmt.Printf("获取用户信息失败: %v\n", err)
+			if ctx.Err() != nil {
+				return
+			}
+			a.logger.Warn("获取用户信息失败，跳过", "endpoint", endpointProfileInfo, "user", user.ID, "err", err)
+			a.markUserSkipped()
 			continue
 		}
 		// 获取用户手机类型
-		phoneType, err := a.weiboService.GetUserPhoneType(user.ID)
+		phoneType, source, err := a.weiboService.GetUserPhoneType(ctx, user.ID)
 		if err != nil {
-			fmt.Printf("获取用户 %s 手机类型失败: %v，跳过\n", user.ID, err)
+			if ctx.Err() != nil {
+				return
+			}
+			a.logger.Warn("获取用户手机类型失败，跳过", "endpoint", endpointBlogs, "user", user.ID, "err", err)
+			a.markUserSkipped()
 			continue
 		}
 		userInfo.PhoneType = phoneType
+		userInfo.Source = source
 
-		ipLocation := a.weiboService.GetUserLocation(user.ID)
+		ipLocation := a.weiboService.GetUserLocation(ctx, user.ID)
+		if ctx.Err() != nil {
+			// 取消时 IP 属地不完整，不记录该用户
+			return
+		}
 		ipLocation = strings.TrimPrefix(ipLocation, "IP属地：")
 		userInfo.IPLocation = ipLocation
 
@@ -122,39 +279,57 @@ func (a *AnalyzerService) processUsers(users []models.CommentUser) {
 
 		// 实时写入用户统计数据到文件
 		a.writeUserStats(userInfo)
+		a.saveUser(userInfo)
 
 		// 更新统计
-		a.updateStatistics(phoneType)
+		a.updateStatistics(userInfo)
+		if a.options.OnUser != nil {
+			a.options.OnUser(userInfo)
+		}
 
 		// 避免请求过于频繁
 		randomMs := rand.Intn(2001) + 1000
-		time.Sleep(time.Duration(cfg.Interval)*time.Second + time.Duration(randomMs)*time.Millisecond)
+		if !sleep(ctx, time.Duration(cfg.Interval)*time.Second+time.Duration(randomMs)*time.Millisecond) {
+			return
+		}
 	}
 }
 
+// markUserSkipped 记录一个因请求失败跳过的用户
+func (a *AnalyzerService) markUserSkipped() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.skippedUsers++
+}
+
 // updateStatistics 更新统计信息
-func (a *AnalyzerService) updateStatistics(phoneType string) {
+func (a *AnalyzerService) updateStatistics(user *models.UserInfo) {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	a.statistics.BrandCounts[phoneType]++
-	a.statistics.UserCount++
+	a.statistics.Add(user)
 }
 
-// writeUserStats 实时写入用户统计数据到文件
+// writeUserStats 实时写入用户记录到各格式的文件
 func (a *AnalyzerService) writeUserStats(user *models.UserInfo) {
-	if a.statsFile == nil {
-		return
-	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 
-	statsLine := fmt.Sprintf("%s,%s,%s,%s,%s,%s\n", user.Id, user.UserName, user.PhoneType, user.Location, user.IPLocation, user.Gender)
-	if _, err := a.statsFile.WriteString(statsLine); err != nil {
-		fmt.Printf("写入统计数据失败: %v\n", err)
+	for _, writer := range a.recordWriters {
+		if err := writer.Write(user); err != nil {
+			a.logger.Error("写入用户记录失败", "user", user.Id, "err", err)
+		}
 	}
+}
 
-	// 立即刷新到磁盘，确保数据不丢失
-	if err := a.statsFile.Sync(); err != nil {
-		fmt.Printf("刷新统计数据文件失败: %v\n", err)
+// saveUser 将用户与设备记录写入数据库
+func (a *AnalyzerService) saveUser(user *models.UserInfo) {
+	runID := a.currentRunID()
+	if a.store == nil || runID == 0 {
+		return
+	}
+	if err := a.store.SaveUser(runID, user); err != nil {
+		a.logger.Error("写入数据库失败", "user", user.Id, "err", err)
 	}
 }
 
@@ -163,23 +338,13 @@ func (a *AnalyzerService) resetStatistics() {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	a.statistics.BrandCounts = make(map[string]int)
-	a.statistics.UserCount = 0
+	a.statistics = models.NewPhoneStatistics()
 	a.processedUsers = make(map[string]bool) // 重置已处理用户集合
+	a.skippedUsers = 0
 
-	// 重置统计数据文件
-	if a.statsFile != nil {
-		a.statsFile.Close()
-		cfg := config.GetGlobalConfig()
-		statsFilePath := filepath.Join(cfg.OutputDir, "stats.txt")
-		statsFile, err := os.OpenFile(statsFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-		if err != nil {
-			fmt.Printf("重置统计数据文件失败: %v\n", err)
-			a.statsFile = nil
-		} else {
-			a.statsFile = statsFile
-		}
-	}
+	// 重置用户记录文件
+	a.closeRecordWriters()
+	a.openRecordWriters(a.options.RecordFormats)
 }
 
 // GetStatistics 获取统计信息
@@ -188,16 +353,7 @@ func (a *AnalyzerService) GetStatistics() *models.PhoneStatistics {
 	defer a.mutex.RUnlock()
 
 	// 创建副本以避免并发问题
-	statistics := &models.PhoneStatistics{
-		BrandCounts: make(map[string]int),
-		UserCount:   a.statistics.UserCount,
-	}
-
-	for k, v := range a.statistics.BrandCounts {
-		statistics.BrandCounts[k] = v
-	}
-
-	return statistics
+	return a.statistics.Clone()
 }
 
 // GetKnownBrandStats 获取已知品牌统计
@@ -246,20 +402,35 @@ func (a *AnalyzerService) GetUnknownBrandStats() []models.StatisticsData {
 	return result
 }
 
-// PrintProgress 打印当前进度
-func (a *AnalyzerService) PrintProgress() {
+// GetProgress 获取当前进度，topN 为附带的已知品牌数
+func (a *AnalyzerService) GetProgress(topN int) Progress {
+	// GetKnownBrandStats 自己加读锁，需在持有锁之前调用
+	knownStats := a.GetKnownBrandStats()
+	if len(knownStats) > topN {
+		knownStats = knownStats[:topN]
+	}
+	requests, requestErrors := a.weiboService.RequestStats()
+
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
 
-	fmt.Printf("当前进度: 已处理 %d 个用户\n", a.statistics.UserCount)
-
-	// 打印已知品牌统计
-	knownStats := a.GetKnownBrandStats()
-	fmt.Println("已知品牌统计:")
-	for _, stat := range knownStats {
-		fmt.Printf("  %s: %d\n", stat.PhoneType, stat.Count)
+	var elapsed time.Duration
+	switch {
+	case a.startedAt.IsZero():
+	case a.finishedAt.IsZero():
+		elapsed = time.Since(a.startedAt)
+	default:
+		elapsed = a.finishedAt.Sub(a.startedAt)
+	}
+	return Progress{
+		Done:          a.statistics.UserCount,
+		Limit:         a.options.Crawl.Limit,
+		Skipped:       a.skippedUsers,
+		Requests:      requests,
+		RequestErrors: requestErrors,
+		Elapsed:       elapsed,
+		TopBrands:     knownStats,
 	}
-	fmt.Println(strings.Repeat("-", 50))
 }
 
 // GetProcessedUserCount 获取已处理用户数量（包括去重统计）
@@ -307,6 +478,23 @@ func (a *AnalyzerService) GetSummary() string {
 		}
 	}
 
+	if stats.UserCount > 0 {
+		builder.WriteString("\n性别分布:\n")
+		for _, stat := range models.SortedCounts(stats.GenderCounts) {
+			builder.WriteString(fmt.Sprintf("  %s: %d (%.1f%%)\n",
+				stat.PhoneType, stat.Count, float64(stat.Count)/float64(stats.UserCount)*100))
+		}
+
+		builder.WriteString("\n前5名IP属地:\n")
+		for i, stat := range models.SortedCounts(stats.IPLocationCounts) {
+			if i >= 5 {
+				break
+			}
+			builder.WriteString(fmt.Sprintf("  %d. %s: %d (%.1f%%)\n",
+				i+1, stat.PhoneType, stat.Count, float64(stat.Count)/float64(stats.UserCount)*100))
+		}
+	}
+
 	if len(unknownStats) > 0 {
 		builder.WriteString(fmt.Sprintf("\n未知品牌数量: %d\n", len(unknownStats)))
 		if len(unknownStats) <= 10 {
@@ -325,16 +513,40 @@ func (a *AnalyzerService) Close() error {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	if a.statsFile != nil {
-		err := a.statsFile.Close()
-		a.statsFile = nil
-		return err
+	err := a.closeRecordWriters()
+	if a.store != nil {
+		if closeErr := a.store.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		a.store = nil
 	}
-	return nil
+	return err
+}
+
+// GetCookieHealth 获取各 Cookie 的请求统计与状态
+func (a *AnalyzerService) GetCookieHealth() []client.CookieHealth {
+	return a.weiboService.CookieHealth()
+}
+
+// Err 返回中止分析的错误，如响应结构异常次数超过阈值；正常结束或被取消时为 nil
+func (a *AnalyzerService) Err() error {
+	if a.weiboService.schema == nil {
+		return nil
+	}
+	return a.weiboService.schema.Err()
+}
+
+// GetSchemaDrift 获取各接口响应结构异常的次数
+func (a *AnalyzerService) GetSchemaDrift() map[string]int {
+	return a.weiboService.SchemaDrift()
+}
+
+// GetProxyHealth 获取各代理的请求统计与状态
+func (a *AnalyzerService) GetProxyHealth() []client.ProxyHealth {
+	return a.weiboService.ProxyHealth()
 }
 
-// GetOutputDir 获取输出目录路径
+// GetOutputDir 获取用户专属输出目录路径
 func (a *AnalyzerService) GetOutputDir() string {
-	cfg := config.GetGlobalConfig()
-	return cfg.OutputDir
+	return a.outputDir
 }
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"comment_phone_analyse/internal/models"
+)
+
+// postState 单条博客评论收集的状态
+type postState int
+
+const (
+	postActive     postState = iota // 仍可继续翻页
+	postExhausted                   // 评论已取完（max_id 为 0）
+	postUserCapped                  // 达到单条博客用户上限
+	postPageCapped                  // 达到单条博客页数上限
+	postFailed                      // 连续失败次数达到上限
+)
+
+// String 返回状态说明
+func (s postState) String() string {
+	switch s {
+	case postActive:
+		return "进行中"
+	case postExhausted:
+		return "评论已取完"
+	case postUserCapped:
+		return "达到单条博客用户上限"
+	case postPageCapped:
+		return "达到单条博客页数上限"
+	case postFailed:
+		return "连续失败次数过多"
+	default:
+		return "未知状态"
+	}
+}
+
+// postLimits 单条博客的收集上限，0 表示不限
+type postLimits struct {
+	users    int
+	pages    int
+	failures int
+}
+
+// newPostLimits 从抓取选项读取单条博客的收集上限
+func newPostLimits(cfg *CrawlOptions) postLimits {
+	return postLimits{
+		users:    cfg.SingleLimit,
+		pages:    cfg.SinglePageLimit,
+		failures: cfg.MaxFailures,
+	}
+}
+
+// postCursor 单条博客的评论翻页状态机
+//
+// 状态只会从 postActive 转移到其余终止状态之一：
+// 翻页成功且 max_id 为 0 时为 postExhausted；收集的用户数达到上限时为 postUserCapped；
+// 翻页数达到上限时为 postPageCapped；连续失败达到上限时为 postFailed。
+// 失败时 max_id 保持不变，下次调用会重试同一页。
+type postCursor struct {
+	blog     models.Blog
+	limits   postLimits
+	state    postState
+	maxID    uint64
+	pages    int
+	users    int
+	failures int
+}
+
+// newCursor 为单条博客创建翻页游标
+func newCursor(blog models.Blog, limits postLimits) *postCursor {
+	return &postCursor{blog: blog, limits: limits}
+}
+
+// newCursors 为博客列表创建翻页游标
+func newCursors(posts []models.Blog, limits postLimits) []*postCursor {
+	cursors := make([]*postCursor, 0, len(posts))
+	for _, post := range posts {
+		cursors = append(cursors, newCursor(post, limits))
+	}
+	return cursors
+}
+
+// done 是否已进入终止状态
+func (c *postCursor) done() bool {
+	return c.state != postActive
+}
+
+// remaining 该博客还能贡献的用户数
+func (c *postCursor) remaining() int {
+	if c.limits.users <= 0 {
+		return int(^uint(0) >> 1)
+	}
+	return c.limits.users - c.users
+}
+
+// advance 抓取下一页评论并推进状态，失败时返回错误且不移动 max_id
+func (c *postCursor) advance(fetch func(maxID uint64) (*models.CommentResponse, error)) ([]models.CommentData, error) {
+	if c.done() {
+		return nil, nil
+	}
+
+	response, err := fetch(c.maxID)
+	if err != nil {
+		c.failures++
+		if c.limits.failures > 0 && c.failures >= c.limits.failures {
+			c.state = postFailed
+		}
+		return nil, err
+	}
+
+	c.failures = 0
+	c.pages++
+	c.maxID = response.MaxID
+
+	switch {
+	case response.MaxID == 0:
+		c.state = postExhausted
+	case c.limits.pages > 0 && c.pages >= c.limits.pages:
+		c.state = postPageCapped
+	}
+	return response.Data, nil
+}
+
+// record 记录本页实际交付的用户数
+func (c *postCursor) record(n int) {
+	c.users += n
+	if c.state == postActive && c.limits.users > 0 && c.users >= c.limits.users {
+		c.state = postUserCapped
+	}
+}
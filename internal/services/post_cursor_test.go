@@ -0,0 +1,260 @@
+package services
+
+import (
+	"comment_phone_analyse/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// pagedComments 模拟评论接口：第i页返回pageSize个用户，最后一页 max_id 为 0
+func pagedComments(pages, pageSize int, calls *[]uint64) func(uint64) (*models.CommentResponse, error) {
+	return func(maxID uint64) (*models.CommentResponse, error) {
+		*calls = append(*calls, maxID)
+		page := int(maxID)
+		response := &models.CommentResponse{}
+		for i := 0; i < pageSize; i++ {
+			response.Data = append(response.Data, models.CommentData{
+				User: models.CommentUser{ID: fmt.Sprintf("%d-%d", page, i)},
+			})
+		}
+		if page+1 < pages {
+			response.MaxID = uint64(page + 1)
+		}
+		return response, nil
+	}
+}
+
+// drain 持续推进游标直到终止，模拟采集循环，返回收集的用户数
+func drain(t *testing.T, cursor *postCursor, fetch func(uint64) (*models.CommentResponse, error)) int {
+	t.Helper()
+	seen := make(userSet)
+	for i := 0; !cursor.done(); i++ {
+		if i > 100 {
+			t.Fatalf("cursor never terminated, state = %s", cursor.state)
+		}
+		comments, err := cursor.advance(fetch)
+		if err != nil {
+			continue
+		}
+		cursor.record(len(seen.take(comments, cursor.remaining())))
+	}
+	return cursor.users
+}
+
+func TestPostCursor_Exhausted(t *testing.T) {
+	var calls []uint64
+	cursor := newCursor(models.Blog{}, postLimits{failures: 3})
+
+	users := drain(t, cursor, pagedComments(3, 5, &calls))
+
+	if cursor.state != postExhausted {
+		t.Errorf("state = %s, want %s", cursor.state, postExhausted)
+	}
+	if users != 15 || cursor.pages != 3 {
+		t.Errorf("users = %d, pages = %d, want 15 users over 3 pages", users, cursor.pages)
+	}
+	if fmt.Sprint(calls) != "[0 1 2]" {
+		t.Errorf("max_id sequence = %v, want [0 1 2]", calls)
+	}
+}
+
+func TestPostCursor_UserCapped(t *testing.T) {
+	var calls []uint64
+	cursor := newCursor(models.Blog{}, postLimits{users: 7, failures: 3})
+
+	users := drain(t, cursor, pagedComments(10, 5, &calls))
+
+	if cursor.state != postUserCapped {
+		t.Errorf("state = %s, want %s", cursor.state, postUserCapped)
+	}
+	if users != 7 || cursor.pages != 2 {
+		t.Errorf("users = %d, pages = %d, want 7 users over 2 pages", users, cursor.pages)
+	}
+}
+
+func TestPostCursor_PageCapped(t *testing.T) {
+	var calls []uint64
+	cursor := newCursor(models.Blog{}, postLimits{pages: 2, failures: 3})
+
+	users := drain(t, cursor, pagedComments(10, 5, &calls))
+
+	if cursor.state != postPageCapped {
+		t.Errorf("state = %s, want %s", cursor.state, postPageCapped)
+	}
+	if users != 10 || cursor.pages != 2 {
+		t.Errorf("users = %d, pages = %d, want 10 users over 2 pages", users, cursor.pages)
+	}
+}
+
+func TestPostCursor_Failed(t *testing.T) {
+	var calls []uint64
+	cursor := newCursor(models.Blog{}, postLimits{failures: 3})
+
+	drain(t, cursor, func(maxID uint64) (*models.CommentResponse, error) {
+		calls = append(calls, maxID)
+		return nil, errors.New("boom")
+	})
+
+	if cursor.state != postFailed {
+		t.Errorf("state = %s, want %s", cursor.state, postFailed)
+	}
+	if len(calls) != 3 {
+		t.Errorf("fetch called %d times, want 3", len(calls))
+	}
+}
+
+func TestPostCursor_RetriesSamePageAfterFailure(t *testing.T) {
+	var calls []uint64
+	pages := pagedComments(3, 1, &calls)
+	failed := false
+	cursor := newCursor(models.Blog{}, postLimits{failures: 2})
+
+	users := drain(t, cursor, func(maxID uint64) (*models.CommentResponse, error) {
+		// 第二页失败一次后恢复，失败计数应被重置
+		if maxID == 1 && !failed {
+			failed = true
+			calls = append(calls, maxID)
+			return nil, errors.New("temporary")
+		}
+		return pages(maxID)
+	})
+
+	if cursor.state != postExhausted {
+		t.Errorf("state = %s, want %s", cursor.state, postExhausted)
+	}
+	if users != 3 {
+		t.Errorf("users = %d, want 3", users)
+	}
+	if fmt.Sprint(calls) != "[0 1 1 2]" {
+		t.Errorf("max_id sequence = %v, want [0 1 1 2]", calls)
+	}
+	if cursor.failures != 0 {
+		t.Errorf("failures = %d, want reset to 0", cursor.failures)
+	}
+}
+
+func TestPostCursor_ExhaustedWinsOverUserCap(t *testing.T) {
+	var calls []uint64
+	cursor := newCursor(models.Blog{}, postLimits{users: 5, failures: 3})
+
+	drain(t, cursor, pagedComments(1, 5, &calls))
+
+	if cursor.state != postExhausted {
+		t.Errorf("state = %s, want %s", cursor.state, postExhausted)
+	}
+}
+
+func TestCollectSequential_StopsOnFailingComments(t *testing.T) {
+	service, getter := newTestWeiboService(map[string]string{
+		"mymblog?uid=42&page=1": `{"data":{"list":[
+			{"mblogid":"A","user":{"idstr":"42"}},
+			{"mblogid":"B","user":{"idstr":"42"}}]}}`,
+		"mymblog?uid=42&page=2": `{"data":{"list":[]}}`,
+		"id=B&":                 `{"data":[{"user":{"idstr":"1"}},{"user":{"idstr":"2"}}],"max_id":0}`,
+	})
+	cfg := &CrawlOptions{UID: "42", Limit: 10, MaxFailures: 2}
+
+	var got []string
+	service.collectSequential(context.Background(), cfg, func(users []models.CommentUser) {
+		for _, user := range users {
+			got = append(got, user.ID)
+		}
+	})
+
+	if fmt.Sprint(got) != "[1 2]" {
+		t.Errorf("collected users = %v, want [1 2]", got)
+	}
+
+	failures := 0
+	for _, url := range getter.requests {
+		if strings.Contains(url, "id=A&") {
+			failures++
+		}
+	}
+	if failures != 2 {
+		t.Errorf("post A requested %d times, want 2 (max_failures)", failures)
+	}
+}
+
+func TestCollectSequential_StopsOnCancel(t *testing.T) {
+	service, getter := newTestWeiboService(map[string]string{
+		"mymblog?uid=42&page=1": `{"data":{"list":[
+			{"mblogid":"A","user":{"idstr":"42"}},
+			{"mblogid":"B","user":{"idstr":"42"}}]}}`,
+		"id=A&": `{"data":[{"user":{"idstr":"1"}}],"max_id":7}`,
+		"id=B&": `{"data":[{"user":{"idstr":"2"}}],"max_id":0}`,
+	})
+	// 间隔足够长，sleep 不响应取消时测试会超时
+	cfg := &CrawlOptions{UID: "42", Limit: 10, Interval: 3600, MaxFailures: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got []string
+	service.collectSequential(ctx, cfg, func(users []models.CommentUser) {
+		for _, user := range users {
+			got = append(got, user.ID)
+		}
+		cancel()
+	})
+
+	if fmt.Sprint(got) != "[1]" {
+		t.Errorf("collected users = %v, want [1]", got)
+	}
+	if len(getter.requests) != 2 {
+		t.Errorf("requests = %v, want no requests after cancel", getter.requests)
+	}
+}
+
+func TestCollectSequential_Filter(t *testing.T) {
+	service, getter := newTestWeiboService(map[string]string{
+		"mymblog?uid=42&page=1": `{"data":{"list":[
+			{"mblogid":"A","comments_count":50,"text_raw":"新机发布","user":{"idstr":"42"}},
+			{"mblogid":"B","comments_count":3,"text_raw":"新机发布","user":{"idstr":"42"}},
+			{"mblogid":"C","comments_count":80,"text_raw":"日常","user":{"idstr":"42"}}]}}`,
+		"mymblog?uid=42&page=2": `{"data":{"list":[]}}`,
+		"id=A&":                 `{"data":[{"user":{"idstr":"1"}}],"max_id":0}`,
+	})
+	cfg := &CrawlOptions{UID: "42", Limit: 10, MaxFailures: 2, Filter: PostFilter{MinComments: 10, Keyword: "新机"}}
+
+	var got []string
+	service.collectSequential(context.Background(), cfg, func(users []models.CommentUser) {
+		for _, user := range users {
+			got = append(got, user.ID)
+		}
+	})
+
+	if fmt.Sprint(got) != "[1]" {
+		t.Errorf("collected users = %v, want [1]", got)
+	}
+	for _, url := range getter.requests {
+		if strings.Contains(url, "id=B&") || strings.Contains(url, "id=C&") {
+			t.Errorf("filtered post requested: %s", url)
+		}
+	}
+}
+
+func TestCollectSequential_PostIDs(t *testing.T) {
+	service, getter := newTestWeiboService(map[string]string{
+		"id=X&": `{"data":[{"user":{"idstr":"1"}},{"user":{"idstr":"2"}}],"max_id":0}`,
+	})
+	cfg := &CrawlOptions{UID: "42", Limit: 10, MaxFailures: 2, PostIDs: []string{"X"}}
+
+	var got []string
+	service.collectSequential(context.Background(), cfg, func(users []models.CommentUser) {
+		for _, user := range users {
+			got = append(got, user.ID)
+		}
+	})
+
+	if fmt.Sprint(got) != "[1 2]" {
+		t.Errorf("collected users = %v, want [1 2]", got)
+	}
+	for _, url := range getter.requests {
+		if strings.Contains(url, "mymblog") {
+			t.Errorf("blog list requested with post_ids: %s", url)
+		}
+	}
+}
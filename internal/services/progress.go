@@ -0,0 +1,64 @@
+package services
+
+import (
+	"comment_phone_analyse/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Progress 分析进行中的进度快照
+type Progress struct {
+	Done          int                     // 已统计的用户数
+	Limit         int                     // 目标用户数
+	Skipped       int                     // 因请求失败跳过的用户数
+	Requests      int64                   // 已发起的请求数
+	RequestErrors int64                   // 失败的请求数
+	Elapsed       time.Duration           // 已用时间
+	TopBrands     []models.StatisticsData // 人数最多的已知品牌
+}
+
+// RequestsPerMinute 平均每分钟的请求数
+func (p Progress) RequestsPerMinute() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Requests) / p.Elapsed.Minutes()
+}
+
+// ETA 按已统计用户的平均速度估算剩余时间，尚无用户完成时返回 false
+func (p Progress) ETA() (time.Duration, bool) {
+	if p.Done == 0 || p.Elapsed <= 0 {
+		return 0, false
+	}
+	if p.Done >= p.Limit {
+		return 0, true
+	}
+	return p.Elapsed / time.Duration(p.Done) * time.Duration(p.Limit-p.Done), true
+}
+
+// String 单行的进度说明
+func (p Progress) String() string {
+	var builder strings.Builder
+	percent := 0.0
+	if p.Limit > 0 {
+		percent = float64(p.Done) / float64(p.Limit) * 100
+	}
+	fmt.Fprintf(&builder, "进度 %d/%d (%.1f%%) | 请求 %d 次，%.1f 次/分，失败 %d | 跳过用户 %d | 已用 %s",
+		p.Done, p.Limit, percent, p.Requests, p.RequestsPerMinute(), p.RequestErrors, p.Skipped, p.Elapsed.Round(time.Second))
+
+	if eta, ok := p.ETA(); ok {
+		fmt.Fprintf(&builder, "，预计剩余 %s", eta.Round(time.Second))
+	} else {
+		builder.WriteString("，预计剩余 未知")
+	}
+
+	if len(p.TopBrands) > 0 {
+		brands := make([]string, 0, len(p.TopBrands))
+		for _, stat := range p.TopBrands {
+			brands = append(brands, fmt.Sprintf("%s %d", stat.PhoneType, stat.Count))
+		}
+		builder.WriteString(" | " + strings.Join(brands, "，"))
+	}
+	return builder.String()
+}
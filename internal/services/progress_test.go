@@ -0,0 +1,58 @@
+package services
+
+import (
+	"comment_phone_analyse/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress_ETA(t *testing.T) {
+	tests := []struct {
+		name   string
+		p      Progress
+		want   time.Duration
+		wantOK bool
+	}{
+		{"nothing done", Progress{Limit: 100, Elapsed: time.Minute}, 0, false},
+		{"quarter done", Progress{Done: 25, Limit: 100, Elapsed: 10 * time.Minute}, 30 * time.Minute, true},
+		{"finished", Progress{Done: 100, Limit: 100, Elapsed: time.Hour}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.p.ETA()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ETA() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAnalyzerService_GetProgress(t *testing.T) {
+	service, _ := newTestWeiboService(nil)
+	analyzer := NewAnalyzerService(service, AnalyzerOptions{
+		Crawl:     CrawlOptions{UID: "42", Limit: 10},
+		OutputDir: t.TempDir(),
+	})
+	defer analyzer.Close()
+
+	for _, brand := range []string{"苹果", "苹果", "华为", "Android"} {
+		analyzer.updateStatistics(&models.UserInfo{PhoneType: brand})
+	}
+	analyzer.markUserSkipped()
+	analyzer.setRunTimes(time.Now().Add(-time.Minute), time.Time{})
+	service.requests.Store(30)
+
+	// 持有读锁时不能再次加读锁，有写者等待时会死锁
+	progress := analyzer.GetProgress(1)
+	if progress.Done != 4 || progress.Skipped != 1 || progress.Requests != 30 {
+		t.Errorf("GetProgress() = %+v", progress)
+	}
+	if len(progress.TopBrands) != 1 || progress.TopBrands[0].PhoneType != "苹果" {
+		t.Errorf("TopBrands = %v, want [苹果 2]", progress.TopBrands)
+	}
+	if line := progress.String(); !strings.Contains(line, "进度 4/10") || !strings.Contains(line, "苹果 2") {
+		t.Errorf("String() = %q", line)
+	}
+}
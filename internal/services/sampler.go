@@ -0,0 +1,285 @@
+package services
+
+import (
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/utils"
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// userSet 评论用户去重集合
+type userSet map[string]bool
+
+// take 从评论中取出至多n个未出现过的用户，并标记为已出现
+func (s userSet) take(comments []models.CommentData, n int) []models.CommentUser {
+	var users []models.CommentUser
+	for _, comment := range comments {
+		if len(users) >= n {
+			break
+		}
+		if comment.User.ID == "" || s[comment.User.ID] {
+			continue
+		}
+		s[comment.User.ID] = true
+		users = append(users, comment.User)
+	}
+	return users
+}
+
+// reservoir 蓄水池抽样（Algorithm R），保证每个候选用户被选中的概率相同
+type reservoir struct {
+	size  int
+	seen  int
+	items []models.CommentUser
+	rng   *rand.Rand
+}
+
+// newReservoir 创建容量为size的蓄水池
+func newReservoir(size int, rng *rand.Rand) *reservoir {
+	return &reservoir{size: size, rng: rng}
+}
+
+// add 向蓄水池提交一个候选用户
+func (r *reservoir) add(user models.CommentUser) {
+	r.seen++
+	if len(r.items) < r.size {
+		r.items = append(r.items, user)
+		return
+	}
+	if j := r.rng.Intn(r.seen); j < r.size {
+		r.items[j] = user
+	}
+}
+
+// allocateQuotas 按评论数为每条博客分配用户配额，使用最大余数法保证总和等于limit
+func allocateQuotas(posts []models.Blog, limit int) []int {
+	quotas := make([]int, len(posts))
+	if len(posts) == 0 {
+		return quotas
+	}
+
+	weights := make([]int, len(posts))
+	totalWeight := 0
+	for i, post := range posts {
+		weights[i] = post.CommentsCount
+		if weights[i] < 0 {
+			weights[i] = 0
+		}
+		totalWeight += weights[i]
+	}
+	// 评论数都未知时平均分配
+	if totalWeight == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		totalWeight = len(weights)
+	}
+
+	remainders := make([]int, len(posts))
+	assigned := 0
+	for i, weight := range weights {
+		quotas[i] = limit * weight / totalWeight
+		remainders[i] = limit * weight % totalWeight
+		assigned += quotas[i]
+	}
+
+	// 剩余名额按余数从大到小依次分配
+	for assigned < limit {
+		best := 0
+		for i := range remainders {
+			if remainders[i] > remainders[best] {
+				best = i
+			}
+		}
+		quotas[best]++
+		remainders[best] = -1
+		assigned++
+	}
+
+	return quotas
+}
+
+// collectPosts 收集目标用户本人发布且符合筛选条件的前n条博客，指定了博客时直接使用
+func (w *WeiboService) collectPosts(ctx context.Context, cfg *CrawlOptions, n int) []models.Blog {
+	if len(cfg.PostIDs) > 0 {
+		w.logger.Info("使用指定的博客采样", "posts", len(cfg.PostIDs))
+		return cfg.givenPosts()
+	}
+
+	var posts []models.Blog
+	for page := 1; len(posts) < n; page++ {
+		blogs, err := w.GetBlogs(ctx, cfg.UID, page)
+		if err != nil {
+			if !errors.Is(err, utils.ErrNoMoreData) && ctx.Err() == nil {
+				w.logger.Warn("获取博客列表失败", "endpoint", endpointBlogs, "page", page, "err", err)
+			}
+			break
+		}
+
+		for _, blog := range blogs {
+			if cfg.accept(blog) && len(posts) < n {
+				posts = append(posts, blog)
+			}
+		}
+
+		if cfg.Filter.before(blogs) {
+			break
+		}
+		if len(posts) < n && !sleep(ctx, time.Duration(cfg.Interval)*time.Second) {
+			break
+		}
+	}
+
+	w.logger.Info("选取博客用于采样", "posts", len(posts))
+	return posts
+}
+
+// collectPage 抓取游标的下一页评论，取出至多n个新用户并记入游标
+//
+// ctx 已取消时不发起请求；请求因取消而失败时不再打印错误，整个抓取随之结束。
+func (w *WeiboService) collectPage(ctx context.Context, cfg *CrawlOptions, cursor *postCursor, seen userSet, n int) []models.CommentUser {
+	if ctx.Err() != nil {
+		return nil
+	}
+	var canceled bool
+	comments, err := cursor.advance(func(maxID uint64) (*models.CommentResponse, error) {
+		response, err := w.GetComments(ctx, cursor.blog.MblogID, cfg.UID, maxID)
+		canceled = err != nil && ctx.Err() != nil
+		return response, err
+	})
+
+	var users []models.CommentUser
+	if canceled {
+		return nil
+	}
+	if err != nil {
+		w.logger.Warn("获取评论失败", "endpoint", endpointComments, "post", cursor.blog.MblogID, "attempt", cursor.failures, "err", err)
+		if !cursor.done() {
+			sleep(ctx, time.Duration(cfg.Interval)*time.Second)
+		}
+	} else {
+		users = seen.take(comments, min(n, cursor.remaining()))
+		cursor.record(len(users))
+		if w.observer != nil && len(users) > 0 {
+			w.observer.OnComments(cursor.blog, users)
+		}
+	}
+
+	if cursor.done() {
+		w.logger.Info("博客停止收集", "post", cursor.blog.MblogID, "state", cursor.state, "pages", cursor.pages, "users", cursor.users)
+	}
+	return users
+}
+
+// roundRobin 在未取完的博客间轮流抓取评论页，直到总数达到limit，返回新的总数
+func (w *WeiboService) roundRobin(ctx context.Context, cfg *CrawlOptions, cursors []*postCursor, seen userSet, total int, callback func([]models.CommentUser)) int {
+	for total < cfg.Limit && ctx.Err() == nil {
+		active := 0
+		for _, cursor := range cursors {
+			if cursor.done() || total >= cfg.Limit || ctx.Err() != nil {
+				continue
+			}
+			active++
+
+			users := w.collectPage(ctx, cfg, cursor, seen, cfg.Limit-total)
+			if len(users) > 0 {
+				callback(users)
+				total += len(users)
+				w.logger.Info("已处理用户", "total", total)
+			}
+		}
+
+		if active == 0 {
+			w.logger.Info("所有博客的评论已取完")
+			break
+		}
+
+		if total < cfg.Limit && !sleep(ctx, time.Duration(cfg.Interval)*time.Second) {
+			break
+		}
+	}
+	return total
+}
+
+// collectRoundRobin 在前N条博客间轮流抓取评论页，避免样本集中在少数博客
+func (w *WeiboService) collectRoundRobin(ctx context.Context, cfg *CrawlOptions, callback func([]models.CommentUser)) {
+	posts := w.collectPosts(ctx, cfg, cfg.SamplePosts)
+	w.roundRobin(ctx, cfg, newCursors(posts, newPostLimits(cfg)), make(userSet), 0, callback)
+}
+
+// collectProportional 按评论数比例为每条博客分配配额，配额未用完的部分轮流补齐
+func (w *WeiboService) collectProportional(ctx context.Context, cfg *CrawlOptions, callback func([]models.CommentUser)) {
+	posts := w.collectPosts(ctx, cfg, cfg.SamplePosts)
+	cursors := newCursors(posts, newPostLimits(cfg))
+	quotas := allocateQuotas(posts, cfg.Limit)
+	seen := make(userSet)
+	total := 0
+	pages := 0
+
+	for i, cursor := range cursors {
+		got := 0
+		if ctx.Err() != nil {
+			return
+		}
+		for !cursor.done() && got < quotas[i] && total < cfg.Limit && ctx.Err() == nil {
+			// 每页之间等待间隔；上一页失败时 collectPage 已经等待过
+			if pages > 0 && cursor.failures == 0 && !sleep(ctx, time.Duration(cfg.Interval)*time.Second) {
+				return
+			}
+			pages++
+			users := w.collectPage(ctx, cfg, cursor, seen, min(quotas[i]-got, cfg.Limit-total))
+			if len(users) > 0 {
+				callback(users)
+				got += len(users)
+				total += len(users)
+				w.logger.Info("已处理用户", "total", total)
+			}
+		}
+		w.logger.Info("博客配额", "post", cursor.blog.MblogID, "quota", quotas[i], "got", got)
+	}
+
+	// 部分博客评论不足配额时，从仍有评论的博客补齐
+	if total < cfg.Limit {
+		w.roundRobin(ctx, cfg, cursors, seen, total, callback)
+	}
+}
+
+// collectReservoir 从前N条博客收集候选用户池，再蓄水池抽样出limit个用户进行分析
+//
+// ctx 取消时放弃抽样，候选池中的用户不再分析。
+func (w *WeiboService) collectReservoir(ctx context.Context, cfg *CrawlOptions, callback func([]models.CommentUser)) {
+	posts := w.collectPosts(ctx, cfg, cfg.SamplePosts)
+	cursors := newCursors(posts, newPostLimits(cfg))
+	seen := make(userSet)
+	sample := newReservoir(cfg.Limit, rand.New(rand.NewSource(time.Now().UnixNano())))
+
+	for sample.seen < cfg.SamplePool {
+		active := 0
+		for _, cursor := range cursors {
+			if cursor.done() || sample.seen >= cfg.SamplePool {
+				continue
+			}
+			active++
+
+			for _, user := range w.collectPage(ctx, cfg, cursor, seen, cfg.SamplePool-sample.seen) {
+				sample.add(user)
+			}
+		}
+
+		if active == 0 {
+			break
+		}
+		w.logger.Info("候选用户池", "seen", sample.seen)
+		if sample.seen < cfg.SamplePool && !sleep(ctx, time.Duration(cfg.Interval)*time.Second) {
+			return
+		}
+	}
+
+	w.logger.Info("候选用户池抽样", "seen", sample.seen, "sampled", len(sample.items))
+	if len(sample.items) > 0 {
+		callback(sample.items)
+		w.logger.Info("已处理用户", "total", len(sample.items))
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"comment_phone_analyse/internal/models"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestAllocateQuotas(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []int
+		limit  int
+		want   []int
+	}{
+		{"proportional", []int{300, 100, 100}, 10, []int{6, 2, 2}},
+		{"largest remainder", []int{1, 1, 1}, 10, []int{4, 3, 3}},
+		{"unknown counts split evenly", []int{0, 0}, 5, []int{3, 2}},
+		{"no posts", nil, 10, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posts []models.Blog
+			for _, count := range tt.counts {
+				posts = append(posts, models.Blog{CommentsCount: count})
+			}
+
+			got := allocateQuotas(posts, tt.limit)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("allocateQuotas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReservoir(t *testing.T) {
+	sample := newReservoir(10, rand.New(rand.NewSource(1)))
+	for i := 0; i < 1000; i++ {
+		sample.add(models.CommentUser{ID: fmt.Sprint(i)})
+	}
+
+	if sample.seen != 1000 {
+		t.Errorf("seen = %d, want 1000", sample.seen)
+	}
+	if len(sample.items) != 10 {
+		t.Fatalf("len(items) = %d, want 10", len(sample.items))
+	}
+
+	// 抽样结果不应只是前10个候选
+	late := 0
+	unique := make(map[string]bool)
+	for _, user := range sample.items {
+		unique[user.ID] = true
+		var id int
+		fmt.Sscan(user.ID, &id)
+		if id >= 10 {
+			late++
+		}
+	}
+	if len(unique) != 10 {
+		t.Errorf("sample contains duplicates: %v", sample.items)
+	}
+	if late == 0 {
+		t.Errorf("sample never replaced initial items: %v", sample.items)
+	}
+}
+
+func TestUserSetTake(t *testing.T) {
+	seen := make(userSet)
+	comments := []models.CommentData{
+		{User: models.CommentUser{ID: "1"}},
+		{User: models.CommentUser{ID: "1"}},
+		{User: models.CommentUser{ID: "2"}},
+		{User: models.CommentUser{ID: "3"}},
+	}
+
+	if got := seen.take(comments, 2); len(got) != 2 || got[0].ID != "1" || got[1].ID != "2" {
+		t.Fatalf("take() = %v, want users 1 and 2", got)
+	}
+	if got := seen.take(comments, 10); len(got) != 1 || got[0].ID != "3" {
+		t.Fatalf("take() = %v, want only user 3", got)
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"comment_phone_analyse/internal/client"
+	"comment_phone_analyse/internal/utils"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 接口名，用于按接口统计响应结构异常
+const (
+	endpointProfileInfo   = "profile/info"
+	endpointProfileDetail = "profile/detail"
+	endpointBlogs         = "statuses/mymblog"
+	endpointComments      = "statuses/buildComments"
+)
+
+// DefaultMaxSchemaDrift 未设置 max_schema_drift 时，同一接口的响应结构异常达到该次数即中止分析
+const DefaultMaxSchemaDrift = 5
+
+// fieldRule 响应中一个字段的预期类型，路径中的 [] 表示数组的每个元素
+type fieldRule struct {
+	path     string
+	kind     string // object、array、string、number
+	optional bool   // 缺失时不算异常，类型不符仍算
+}
+
+// responseSchemas 各接口用到的字段；字段改名后 json.Unmarshal 只会得到零值，需要在这里发现
+var responseSchemas = map[string][]fieldRule{
+	endpointProfileInfo: {
+		{path: "data.user", kind: "object"},
+		{path: "data.user.idstr", kind: "string"},
+		{path: "data.user.screen_name", kind: "string", optional: true},
+		{path: "data.user.gender", kind: "string", optional: true},
+		{path: "data.user.location", kind: "string", optional: true},
+	},
+	endpointProfileDetail: {
+		{path: "data", kind: "object"},
+		{path: "data.ip_location", kind: "string", optional: true},
+	},
+	endpointBlogs: {
+		{path: "data.list", kind: "array"},
+		{path: "data.list[].mblogid", kind: "string"},
+		{path: "data.list[].source", kind: "string"},
+		{path: "data.list[].user.idstr", kind: "string"},
+		{path: "data.list[].comments_count", kind: "number", optional: true},
+		{path: "data.list[].created_at", kind: "string", optional: true},
+		{path: "data.list[].text_raw", kind: "string", optional: true},
+	},
+	endpointComments: {
+		{path: "data", kind: "array"},
+		{path: "data[].user.idstr", kind: "string"},
+		{path: "max_id", kind: "number"},
+	},
+}
+
+// validateResponse 检查响应的 ok 字段与各字段的类型
+//
+// ok 不为 1 时返回错误（-100 表示 Cookie 失效）；字段缺失或类型不符作为结构异常返回，不影响解析。
+func validateResponse(endpoint string, body []byte) ([]string, error) {
+	var response map[string]any
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, utils.NewParseError(fmt.Sprintf("接口 %s 返回的不是 JSON 对象", endpoint), err)
+	}
+
+	problems := make(map[string]bool)
+	switch ok := response["ok"].(type) {
+	case float64:
+		if ok == -100 {
+			return nil, utils.NewAuthError("Cookie 无效或已过期（ok=-100）", client.ErrAuth)
+		}
+		if ok != 1 {
+			message, _ := response["msg"].(string)
+			return nil, utils.NewParseError(fmt.Sprintf("接口 %s 返回 ok=%v %s", endpoint, ok, message), utils.ErrInvalidResponse)
+		}
+	case nil:
+		problems["ok 缺失"] = true
+	default:
+		problems[fmt.Sprintf("ok 类型为 %s，应为 number", kindOf(ok))] = true
+	}
+
+	for _, rule := range responseSchemas[endpoint] {
+		checkField(response, "", strings.Split(rule.path, "."), rule, problems)
+	}
+
+	anomalies := make([]string, 0, len(problems))
+	for problem := range problems {
+		anomalies = append(anomalies, problem)
+	}
+	sort.Strings(anomalies)
+	return anomalies, nil
+}
+
+// checkField 沿路径查找字段并检查类型，null 视为缺失；prefix 为已经过的路径
+func checkField(value any, prefix string, segments []string, rule fieldRule, problems map[string]bool) {
+	if len(segments) == 0 {
+		if kind := kindOf(value); value != nil && kind != rule.kind {
+			problems[fmt.Sprintf("%s 类型为 %s，应为 %s", rule.path, kind, rule.kind)] = true
+		}
+		return
+	}
+
+	object, _ := value.(map[string]any)
+	name, each := strings.CutSuffix(segments[0], "[]")
+	path := strings.TrimPrefix(prefix+"."+name, ".")
+	child := object[name]
+	if child == nil {
+		if !rule.optional {
+			problems[rule.path+" 缺失"] = true
+		}
+		return
+	}
+	if !each {
+		checkField(child, path, segments[1:], rule, problems)
+		return
+	}
+
+	items, ok := child.([]any)
+	if !ok {
+		problems[fmt.Sprintf("%s 类型为 %s，应为 array", path, kindOf(child))] = true
+		return
+	}
+	for _, item := range items {
+		checkField(item, path+"[]", segments[1:], rule, problems)
+	}
+}
+
+// kindOf JSON 值的类型名
+func kindOf(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// schemaMonitor 按接口统计响应结构异常，保存异常的响应，超过阈值时中止分析
+type schemaMonitor struct {
+	maxDrift int // 负数表示不中止
+	logger   *slog.Logger
+
+	mutex   sync.Mutex
+	counts  map[string]int
+	dumpDir string      // 保存异常响应的目录，为空时不保存
+	abort   func(error) // 达到阈值时调用，可为 nil
+	err     error       // 中止分析的错误
+}
+
+// newSchemaMonitor 创建结构异常统计，maxDrift 为 0 时使用 DefaultMaxSchemaDrift，负数表示不中止
+func newSchemaMonitor(maxDrift int, logger *slog.Logger) *schemaMonitor {
+	if maxDrift == 0 {
+		maxDrift = DefaultMaxSchemaDrift
+	}
+	return &schemaMonitor{maxDrift: maxDrift, logger: logger, counts: make(map[string]int)}
+}
+
+// setDumpDir 设置保存异常响应的目录
+func (m *schemaMonitor) setDumpDir(dir string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.dumpDir = dir
+}
+
+// reset 清零统计并设置达到阈值时的回调
+func (m *schemaMonitor) reset(abort func(error)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.counts = make(map[string]int)
+	m.abort = abort
+	m.err = nil
+}
+
+// record 记录一次结构异常并保存响应，同一接口达到阈值时中止分析
+func (m *schemaMonitor) record(endpoint string, body []byte, anomalies []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.counts[endpoint]++
+	count := m.counts[endpoint]
+	m.logger.Warn("响应结构与预期不符", "endpoint", endpoint, "count", count, "anomalies", strings.Join(anomalies, "；"))
+
+	dump := ""
+	if m.dumpDir != "" {
+		dump = filepath.Join(m.dumpDir, "schema_drift_"+strings.ReplaceAll(endpoint, "/", "_")+".json")
+		if err := os.WriteFile(dump, body, 0644); err != nil {
+			m.logger.Error("保存异常响应失败", "endpoint", endpoint, "err", err)
+			dump = ""
+		}
+	}
+
+	if m.maxDrift > 0 && count == m.maxDrift && m.err == nil {
+		message := fmt.Sprintf("接口 %s 的响应结构累计异常 %d 次，微博可能修改了接口，已中止分析", endpoint, count)
+		if dump != "" {
+			message += "，最近一次响应已保存到 " + dump
+		}
+		m.err = utils.NewParseError(message, utils.ErrSchemaDrift)
+		if m.abort != nil {
+			m.abort(m.err)
+		}
+	}
+}
+
+// Counts 返回各接口的结构异常次数
+func (m *schemaMonitor) Counts() map[string]int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	counts := make(map[string]int, len(m.counts))
+	for endpoint, count := range m.counts {
+		counts[endpoint] = count
+	}
+	return counts
+}
+
+// Err 返回中止分析的错误，未中止时为 nil
+func (m *schemaMonitor) Err() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.err
+}
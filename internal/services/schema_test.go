@@ -0,0 +1,104 @@
+package services
+
+import (
+	"comment_phone_analyse/internal/utils"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		body     string
+		want     string // 结构异常，按字母序以 "|" 连接
+		wantCode int    // 期望的退出码，0 表示没有错误
+	}{
+		{"valid blogs", endpointBlogs,
+			`{"ok":1,"data":{"list":[{"mblogid":"A","source":"iPhone","comments_count":3,"user":{"idstr":"1"}}]}}`, "", 0},
+		{"renamed source", endpointBlogs,
+			`{"ok":1,"data":{"list":[{"mblogid":"A","device":"iPhone","user":{"idstr":"1"}},{"mblogid":"B","device":"","user":{"idstr":"1"}}]}}`,
+			"data.list[].source 缺失", 0},
+		{"string count", endpointBlogs,
+			`{"ok":1,"data":{"list":[{"mblogid":"A","source":"","comments_count":"3","user":{"idstr":"1"}}]}}`,
+			"data.list[].comments_count 类型为 string，应为 number", 0},
+		{"list is object", endpointBlogs, `{"ok":1,"data":{"list":{}}}`, "data.list 类型为 object，应为 array", 0},
+		{"missing ok", endpointComments, `{"data":[],"max_id":0}`, "ok 缺失", 0},
+		{"comments", endpointComments, `{"ok":1,"data":[{"user":{"idstr":"1"}},{"user":null}],"max_id":0}`, "data[].user.idstr 缺失", 0},
+		{"optional ip location", endpointProfileDetail, `{"ok":1,"data":{}}`, "", 0},
+		{"numeric ip location", endpointProfileDetail, `{"ok":1,"data":{"ip_location":1}}`, "data.ip_location 类型为 number，应为 string", 0},
+		{"expired cookie", endpointProfileInfo, `{"ok":-100,"url":"https://passport.weibo.com"}`, "", utils.ExitAuth},
+		{"api error", endpointProfileInfo, `{"ok":0,"msg":"用户不存在"}`, "", utils.ExitParse},
+		{"not json", endpointProfileInfo, `<html></html>`, "", utils.ExitParse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anomalies, err := validateResponse(tt.endpoint, []byte(tt.body))
+			if utils.ExitCode(err) != tt.wantCode {
+				t.Fatalf("validateResponse() error = %v, want exit code %d", err, tt.wantCode)
+			}
+			if got := strings.Join(anomalies, "|"); got != tt.want {
+				t.Errorf("anomalies = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeiboService_SchemaDrift(t *testing.T) {
+	dir := t.TempDir()
+	service, _ := newTestWeiboService(map[string]string{
+		"mymblog": `{"ok":1,"data":{"list":[{"mblogid":"A","device":"iPhone","user":{"idstr":"42"}}]}}`,
+	})
+	service.schema = newSchemaMonitor(2, service.logger)
+	service.schema.setDumpDir(dir)
+
+	ctx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+	service.schema.reset(abort)
+
+	// 字段改名时仍正常返回，只记录异常
+	if _, _, err := service.GetUserPhoneType(ctx, "42"); err != nil {
+		t.Fatalf("GetUserPhoneType() error = %v", err)
+	}
+	if ctx.Err() != nil || service.schema.Err() != nil {
+		t.Fatal("aborted before reaching max_schema_drift")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "schema_drift_statuses_mymblog.json")); err != nil {
+		t.Errorf("offending body not saved: %v", err)
+	}
+
+	service.GetUserPhoneType(ctx, "42")
+	err := service.schema.Err()
+	if !errors.Is(err, utils.ErrSchemaDrift) || utils.ExitCode(err) != utils.ExitParse {
+		t.Errorf("Err() = %v, want schema drift parse error", err)
+	}
+	if !errors.Is(context.Cause(ctx), utils.ErrSchemaDrift) {
+		t.Errorf("crawl not aborted: cause = %v", context.Cause(ctx))
+	}
+	if drift := service.SchemaDrift(); drift[endpointBlogs] != 2 {
+		t.Errorf("SchemaDrift() = %v, want 2 for %s", drift, endpointBlogs)
+	}
+}
+
+func TestNewSchemaMonitor_MaxDrift(t *testing.T) {
+	if m := newSchemaMonitor(0, slog.Default()); m.maxDrift != DefaultMaxSchemaDrift {
+		t.Errorf("maxDrift = %d, want DefaultMaxSchemaDrift", m.maxDrift)
+	}
+
+	// 负数时只记录，从不中止
+	m := newSchemaMonitor(-1, slog.Default())
+	aborted := false
+	m.reset(func(error) { aborted = true })
+	for i := 0; i < DefaultMaxSchemaDrift*2; i++ {
+		m.record(endpointBlogs, nil, []string{"source 缺失"})
+	}
+	if aborted || m.Err() != nil {
+		t.Errorf("monitor with negative max drift aborted: %v", m.Err())
+	}
+}
@@ -5,30 +5,213 @@ import (
 	"comment_phone_analyse/internal/client"
 	"comment_phone_analyse/internal/models"
 	"comment_phone_analyse/internal/utils"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// getter 抽象GET请求，便于测试时替换客户端
+type getter interface {
+	Get(ctx context.Context, url string) ([]byte, error)
+}
+
 // WeiboService 微博服务
 type WeiboService struct {
-	client       *client.Client
-	phoneMapping models.PhoneBrandMapping
+	client        getter
+	phoneMapping  models.PhoneBrandMapping
+	observer      CrawlObserver
+	logger        *slog.Logger
+	schema        *schemaMonitor // 响应结构校验，为 nil 时不校验
+	requests      atomic.Int64   // 已发起的请求数
+	requestErrors atomic.Int64   // 失败的请求数，不含取消
+}
+
+// CrawlObserver 接收抓取过程中采样到的博客与评论用户，用于持久化原始数据
+type CrawlObserver interface {
+	OnComments(blog models.Blog, users []models.CommentUser)
+}
+
+// SetObserver 设置抓取观察者，传入 nil 取消
+func (w *WeiboService) SetObserver(observer CrawlObserver) {
+	w.observer = observer
+}
+
+// WeiboOptions 微博服务的选项
+type WeiboOptions struct {
+	Cookie       string
+	Cookies      []string                 // 轮换使用的多个 Cookie，不为空时忽略 Cookie
+	Client       client.Options           // 每个 Cookie 的请求预算与冷却时长
+	PhoneMapping models.PhoneBrandMapping // 设备来源到品牌的映射，为 nil 时使用内置映射
+	MaxDrift     int                      // 同一接口的响应结构异常达到该次数时中止分析，0 表示 DefaultMaxSchemaDrift，负数表示不中止
+	Logger       *slog.Logger             // 为 nil 时使用 slog.Default()
+}
+
+// CrawlOptions 评论用户的抓取与采样选项
+type CrawlOptions struct {
+	UID             string // 目标用户ID
+	Limit           int    // 收集的用户总数
+	Interval        int    // 请求间隔秒数
+	SampleStrategy  string // 采样策略，取值见 config.Sample*，为空时按博客顺序收集
+	SamplePosts     int    // 参与采样的博客数
+	SamplePool      int    // reservoir 策略的候选池大小
+	SingleLimit     int    // 单条博客最多收集的用户数，0 表示不限
+	SinglePageLimit int    // 单条博客最多翻的评论页数，0 表示不限
+	MaxFailures     int    // 单条博客评论连续失败多少次后放弃
+
+	PostIDs []string   // 只收集这些博客（mblogid）的评论，为空时从目标用户的博客列表中选取
+	Filter  PostFilter // 从博客列表中选取时的筛选条件，对 PostIDs 不生效
+}
+
+// PostFilter 参与采样的博客的筛选条件，零值表示不筛选
+type PostFilter struct {
+	MinComments int       // 评论数下限
+	Since       time.Time // 发布时间下限
+	Until       time.Time // 发布时间上限（不含）
+	Keyword     string    // 正文需包含的关键词
+}
+
+// match 博客是否符合筛选条件，发布时间无法解析的博客不受时间条件限制
+func (f PostFilter) match(blog models.Blog) bool {
+	if blog.CommentsCount < f.MinComments {
+		return false
+	}
+	if f.Keyword != "" && !strings.Contains(blog.Text, f.Keyword) {
+		return false
+	}
+	if created, ok := blog.Created(); ok {
+		if !f.Since.IsZero() && created.Before(f.Since) {
+			return false
+		}
+		if !f.Until.IsZero() && !created.Before(f.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// before 一页博客是否全部早于 Since；博客按时间倒序排列，此后的页面不会再有符合条件的博客
+func (f PostFilter) before(blogs []models.Blog) bool {
+	if f.Since.IsZero() || len(blogs) == 0 {
+		return false
+	}
+	for _, blog := range blogs {
+		created, ok := blog.Created()
+		if !ok || !created.Before(f.Since) {
+			return false
+		}
+	}
+	return true
+}
+
+// accept 博客是否由目标用户发布且符合筛选条件
+func (cfg *CrawlOptions) accept(blog models.Blog) bool {
+	return blog.User.ID == cfg.UID && cfg.Filter.match(blog)
+}
+
+// givenPosts 将指定的博客ID转换为博客，评论数等信息未知
+func (cfg *CrawlOptions) givenPosts() []models.Blog {
+	posts := make([]models.Blog, 0, len(cfg.PostIDs))
+	for _, id := range cfg.PostIDs {
+		posts = append(posts, models.Blog{MblogID: id, User: models.User{ID: cfg.UID}})
+	}
+	return posts
 }
 
 // NewWeiboService 创建微博服务
-func NewWeiboService() *WeiboService {
-	cfg := config.GetGlobalConfig()
+func NewWeiboService(opts WeiboOptions) *WeiboService {
+	mapping := opts.PhoneMapping
+	if mapping == nil {
+		mapping = models.DefaultPhoneMapping()
+	}
+	cookies := opts.Cookies
+	if len(cookies) == 0 {
+		cookies = []string{opts.Cookie}
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if opts.Client.Logger == nil {
+		opts.Client.Logger = logger
+	}
 	return &WeiboService{
-		client:       client.NewClient(cfg.Cookie),
-		phoneMapping: getDefaultPhoneMapping(),
+		client:       client.NewPool(cookies, opts.Client),
+		phoneMapping: mapping,
+		schema:       newSchemaMonitor(opts.MaxDrift, logger),
+		logger:       logger,
 	}
 }
 
-func (w *WeiboService) GetUserInfo(uid string) (*models.UserInfo, error) {
+// decode 校验响应后解析到 v；结构异常只记录，不影响解析
+func (w *WeiboService) decode(endpoint string, body []byte, v any) error {
+	if w.schema != nil {
+		anomalies, err := validateResponse(endpoint, body)
+		if err != nil {
+			return err
+		}
+		if len(anomalies) > 0 {
+			w.schema.record(endpoint, body, anomalies)
+		}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return utils.NewParseError(fmt.Sprintf("解析 %s 响应失败", endpoint), err)
+	}
+	return nil
+}
+
+// SchemaDrift 返回各接口响应结构异常的次数
+func (w *WeiboService) SchemaDrift() map[string]int {
+	if w.schema == nil {
+		return nil
+	}
+	return w.schema.Counts()
+}
+
+// get 发起请求并计数
+func (w *WeiboService) get(ctx context.Context, url string) ([]byte, error) {
+	w.requests.Add(1)
+	body, err := w.client.Get(ctx, url)
+	if err != nil && ctx.Err() == nil {
+		w.requestErrors.Add(1)
+	}
+	return body, err
+}
+
+// CookieHealth 返回各 Cookie 的请求统计与状态
+func (w *WeiboService) CookieHealth() []client.CookieHealth {
+	if pool, ok := w.client.(*client.Client); ok {
+		return pool.Health()
+	}
+	return nil
+}
+
+// ProxyHealth 返回各代理的请求统计与状态
+func (w *WeiboService) ProxyHealth() []client.ProxyHealth {
+	if pool, ok := w.client.(*client.Client); ok {
+		return pool.ProxyHealth()
+	}
+	return nil
+}
+
+// RequestStats 返回已发起的请求数与其中失败的次数
+func (w *WeiboService) RequestStats() (requests, errors int64) {
+	return w.requests.Load(), w.requestErrors.Load()
+}
+
+// ResetRequestStats 清零请求计数
+func (w *WeiboService) ResetRequestStats() {
+	w.requests.Store(0)
+	w.requestErrors.Store(0)
+}
+
+func (w *WeiboService) GetUserInfo(ctx context.Context, uid string) (*models.UserInfo, error) {
 	url := fmt.Sprintf("https://weibo.com/ajax/profile/info?uid=%v", uid)
-	body, err := w.client.Get(url)
+	body, err := w.get(ctx, url)
 	if err != nil {
 		return nil, utils.NewNetworkError("获取博客列表失败", err)
 	}
@@ -38,15 +221,43 @@ func (w *WeiboService) GetUserInfo(uid string) (*models.UserInfo, error) {
 			User models.UserInfo `json:"user"`
 		} `json:"data"`
 	}
+	if err := w.decode(endpointProfileInfo, body, &response); err != nil {
+		return nil, err
+	}
+	return &response.Data.User, nil
+}
+
+// CheckCookie 用 Cookie 请求用户资料，判断 Cookie 是否仍然有效
+//
+// Cookie 失效时微博返回 ok 不为 1 的 JSON 或登录页 HTML，两种情况都视为认证失败。
+func (w *WeiboService) CheckCookie(ctx context.Context, uid string) (*models.UserInfo, error) {
+	url := fmt.Sprintf("https://weibo.com/ajax/profile/info?uid=%v", uid)
+	body, err := w.get(ctx, url)
+	if errors.Is(err, client.ErrAuth) {
+		return nil, utils.NewAuthError("Cookie 无效或已过期", err)
+	}
+	if err != nil {
+		return nil, utils.NewNetworkError("请求用户资料失败", err)
+	}
+
+	var response struct {
+		OK   int `json:"ok"`
+		Data struct {
+			User models.UserInfo `json:"user"`
+		} `json:"data"`
+	}
 	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, utils.NewParseError("解析博客数据失败", err)
+		return nil, utils.NewAuthError("Cookie 无效或已过期（返回的不是 JSON）", err)
+	}
+	if response.OK != 1 || response.Data.User.Id == "" {
+		return nil, utils.NewAuthError(fmt.Sprintf("Cookie 无效或已过期（ok=%d）", response.OK), nil)
 	}
 	return &response.Data.User, nil
 }
 
-func (w *WeiboService) GetUserLocation(uid string) string {
+func (w *WeiboService) GetUserLocation(ctx context.Context, uid string) string {
 	url := fmt.Sprintf("https://weibo.com/ajax/profile/detail?uid=%v", uid)
-	body, err := w.client.Get(url)
+	body, err := w.get(ctx, url)
 	if err != nil {
 		return ""
 	}
@@ -56,24 +267,24 @@ func (w *WeiboService) GetUserLocation(uid string) string {
 			IPLocation string `json:"ip_location"`
 		} `json:"data"`
 	}
-	if err := json.Unmarshal(body, &response); err != nil {
+	if err := w.decode(endpointProfileDetail, body, &response); err != nil {
 		return ""
 	}
 	return response.Data.IPLocation
 }
 
 // GetBlogs 获取用户博客列表
-func (w *WeiboService) GetBlogs(uid string, page int) ([]models.Blog, error) {
+func (w *WeiboService) GetBlogs(ctx context.Context, uid string, page int) ([]models.Blog, error) {
 	url := fmt.Sprintf("https://weibo.com/ajax/statuses/mymblog?uid=%s&page=%d&feature=0", uid, page)
 
-	body, err := w.client.Get(url)
+	body, err := w.get(ctx, url)
 	if err != nil {
 		return nil, utils.NewNetworkError("获取博客列表失败", err)
 	}
 
 	var response models.BlogResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, utils.NewParseError("解析博客数据失败", err)
+	if err := w.decode(endpointBlogs, body, &response); err != nil {
+		return nil, err
 	}
 
 	if len(response.Data.List) == 0 {
@@ -84,182 +295,145 @@ func (w *WeiboService) GetBlogs(uid string, page int) ([]models.Blog, error) {
 }
 
 // GetComments 获取博客评论用户列表
-func (w *WeiboService) GetComments(blogID string, uid string, max_id uint64) (*models.CommentResponse, error) {
+func (w *WeiboService) GetComments(ctx context.Context, blogID string, uid string, max_id uint64) (*models.CommentResponse, error) {
 	url := fmt.Sprintf("https://weibo.com/ajax/statuses/buildComments?flow=0&is_reload=1&id=%s&is_show_bulletin=2&is_mix=0&count=20&uid=%s&fetch_level=0&locale=zh-CN&max_id=%v", blogID, uid, max_id)
 
-	body, err := w.client.Get(url)
+	body, err := w.get(ctx, url)
 	if err != nil {
 		return nil, utils.NewNetworkError("获取评论列表失败", err)
 	}
 
 	var response models.CommentResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, utils.NewParseError("解析评论数据失败", err)
+	if err := w.decode(endpointComments, body, &response); err != nil {
+		return nil, err
 	}
 
 	return &response, nil
 }
 
-// GetUserPhoneType 获取用户手机类型
-func (w *WeiboService) GetUserPhoneType(uid string) (string, error) {
-	blogs, err := w.GetBlogs(uid, 1)
+// GetUserPhoneType 获取用户手机品牌及其设备来源原文，优先返回已知品牌
+func (w *WeiboService) GetUserPhoneType(ctx context.Context, uid string) (string, string, error) {
+	blogs, err := w.GetBlogs(ctx, uid, 1)
 	if err != nil {
-		return "", fmt.Errorf("获取用户博客失败: %w", err)
+		return "", "", fmt.Errorf("获取用户博客失败: %w", err)
 	}
-	userPhone := ""
+	userPhone, userSource := "", ""
 	for _, blog := range blogs {
 		if blog.User.ID == uid && blog.PhoneType != "" {
-			curBrand := w.phoneMapping.GetBrand(blog.PhoneType)
+			curBrand := models.ClassifySource(w.phoneMapping, blog.PhoneType)
 			if w.IsKnownBrand(curBrand) {
-				return curBrand, nil
-			} else {
-				userPhone = curBrand
+				return curBrand, blog.PhoneType, nil
+			} else if userPhone == "" {
+				userPhone, userSource = curBrand, blog.PhoneType
 			}
 		}
 	}
 	if userPhone != "" {
-		return userPhone, nil
+		return userPhone, userSource, nil
 	}
-	return "未知设备", nil
+	return models.UnknownDevice, "", nil
 }
 
-// GetUserBlogsAndComments 获取用户博客和评论用户
-func (w *WeiboService) GetUserBlogsAndComments(callback func([]models.CommentUser)) {
-	cfg := config.GetGlobalConfig()
+// GetUserBlogsAndComments 获取用户博客和评论用户，按选项中的采样策略分发
+//
+// ctx 取消后不再发起新的请求，进行中的请求与等待立即中止。
+func (w *WeiboService) GetUserBlogsAndComments(ctx context.Context, opts CrawlOptions, callback func([]models.CommentUser)) {
+	cfg := &opts
+
+	switch cfg.SampleStrategy {
+	case config.SampleRoundRobin:
+		w.collectRoundRobin(ctx, cfg, callback)
+	case config.SampleProportional:
+		w.collectProportional(ctx, cfg, callback)
+	case config.SampleReservoir:
+		w.collectReservoir(ctx, cfg, callback)
+	default:
+		w.collectSequential(ctx, cfg, callback)
+	}
+}
+
+// sleep 等待 d，ctx 取消时提前返回 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
 
-	page := 1
+// collectSequential 按博客顺序依次收集评论用户
+func (w *WeiboService) collectSequential(ctx context.Context, cfg *CrawlOptions, callback func([]models.CommentUser)) {
 	totalProcessed := 0
-	processedUsers := make(map[string]bool)
+	seen := make(userSet)
+	limits := newPostLimits(cfg)
+
+	// 指定了博客时只收集这些博客
+	if len(cfg.PostIDs) > 0 {
+		for _, blog := range cfg.givenPosts() {
+			totalProcessed = w.drainPost(ctx, cfg, newCursor(blog, limits), seen, totalProcessed, callback)
+			if totalProcessed >= cfg.Limit || ctx.Err() != nil {
+				break
+			}
+		}
+		return
+	}
 
-	for totalProcessed < cfg.Limit {
+	for page := 1; totalProcessed < cfg.Limit; page++ {
 		// 获取博客列表
-		blogs, err := w.GetBlogs(cfg.UID, page)
+		blogs, err := w.GetBlogs(ctx, cfg.UID, page)
 		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
 			if errors.Is(err, utils.ErrNoMoreData) {
-				fmt.Println("没有更多博客了")
+				w.logger.Info("没有更多博客了", "page", page)
 				break
 			}
-			fmt.Printf("获取第%d页博客失败: %v\n", page, err)
+			w.logger.Warn("获取博客列表失败", "endpoint", endpointBlogs, "page", page, "err", err)
 			break
 		}
 
 		// 处理每条博客的评论
 		for _, blog := range blogs {
-			// 只处理用户本人发布的博客
-			if blog.User.ID != cfg.UID {
+			// 只处理用户本人发布且符合筛选条件的博客
+			if !cfg.accept(blog) {
 				continue
 			}
 
-			if totalProcessed >= cfg.Limit {
+			totalProcessed = w.drainPost(ctx, cfg, newCursor(blog, limits), seen, totalProcessed, callback)
+			if totalProcessed >= cfg.Limit || ctx.Err() != nil {
 				break
 			}
-
-			singleCount := 0
-			maxID := uint64(0)
-			isFirstPage := true
-
-			// 获取该博客的所有评论
-			for maxID != 0 || isFirstPage {
-				isFirstPage = false
-
-				response, err := w.GetComments(blog.MblogID, cfg.UID, maxID)
-				if err != nil {
-					fmt.Printf("获取评论失败: %v, 跳过博客 %s\n", err, blog.MblogID)
-					continue
-				}
-
-				maxID = response.MaxID
-
-				// 过滤重复用户
-				var newUsers []models.CommentUser
-				for _, comment := range response.Data {
-					if !processedUsers[comment.User.ID] {
-						processedUsers[comment.User.ID] = true
-						newUsers = append(newUsers, comment.User)
-					}
-				}
-
-				// 调用回调处理新用户
-				if len(newUsers) > 0 {
-					callback(newUsers)
-					totalProcessed += len(newUsers)
-					singleCount += len(newUsers)
-					fmt.Printf("已处理 %d 个用户\n", totalProcessed)
-				}
-
-				// 检查限制
-				if singleCount >= cfg.SingleLimit || totalProcessed >= cfg.Limit {
-					break
-				}
-			}
 		}
 
-		if totalProcessed >= cfg.Limit {
+		if totalProcessed >= cfg.Limit || cfg.Filter.before(blogs) {
 			break
 		}
 
 		// 页面间延迟
-		time.Sleep(time.Duration(cfg.Interval) * time.Second)
-		page++
+		if !sleep(ctx, time.Duration(cfg.Interval)*time.Second) {
+			break
+		}
 	}
 }
 
-// getDefaultPhoneMapping 获取默认的手机品牌映射
-func getDefaultPhoneMapping() models.PhoneBrandMapping {
-	return models.PhoneBrandMapping{
-		"Huawei":    "华为",
-		"华为":        "华为",
-		"nova":      "华为",
-		"HarmonyOS": "华为",
-		"Xiaomi":    "小米",
-		"小米":        "小米",
-		"OPPO":      "OPPO",
-		"Find":      "OPPO",
-		"Reno":      "OPPO",
-		"Vivo":      "Vivo",
-		"iPhone":    "苹果",
-		"苹果":        "苹果",
-		"Samsung":   "三星",
-		"三星":        "三星",
-		"Meizu":     "魅族",
-		"魅族":        "魅族",
-		"realme":    "真我",
-		"真我":        "真我",
-		"redmi":     "红米",
-		"红米":        "红米",
-		"一加":        "一加",
-		"OnePlus":   "一加",
-		"荣耀":        "荣耀",
-		"Honor":     "荣耀",
-		"honor":     "荣耀",
-		"ZTE":       "中兴",
-		"中兴":        "中兴",
-		"Nubia":     "努比亚",
-		"努比亚":       "努比亚",
-		"IQOO":      "IQOO",
-		"Neo5":      "IQOO",
-		"Android":   "Android设备",
+// drainPost 翻页直到该博客进入终止状态或总数达到 limit，返回新的总数
+func (w *WeiboService) drainPost(ctx context.Context, cfg *CrawlOptions, cursor *postCursor, seen userSet, total int, callback func([]models.CommentUser)) int {
+	for !cursor.done() && total < cfg.Limit && ctx.Err() == nil {
+		users := w.collectPage(ctx, cfg, cursor, seen, cfg.Limit-total)
+		if len(users) > 0 {
+			callback(users)
+			total += len(users)
+			w.logger.Info("已处理用户", "total", total)
+		}
 	}
+	return total
 }
 
 // IsKnownBrand 检查是否为已知品牌
 func (w *WeiboService) IsKnownBrand(phoneType string) bool {
-	knownBrands := map[string]bool{
-		"华为":        true,
-		"小米":        true,
-		"OPPO":      true,
-		"Vivo":      true,
-		"苹果":        true,
-		"三星":        true,
-		"魅族":        true,
-		"真我":        true,
-		"红米":        true,
-		"一加":        true,
-		"荣耀":        true,
-		"中兴":        true,
-		"努比亚":       true,
-		"IQOO":      true,
-		"未知Android": true,
-	}
-	return knownBrands[phoneType]
+	return models.IsKnownBrand(phoneType)
 }
@@ -1,7 +1,141 @@
 package services
 
-import "testing"
+import (
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/utils"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGetter 按URL片段返回预设响应
+type fakeGetter struct {
+	responses map[string]string
+	requests  []string
+}
+
+func (f *fakeGetter) Get(ctx context.Context, url string) ([]byte, error) {
+	f.requests = append(f.requests, url)
+	for fragment, body := range f.responses {
+		if strings.Contains(url, fragment) {
+			return []byte(body), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected url: %s", url)
+}
+
+func newTestWeiboService(responses map[string]string) (*WeiboService, *fakeGetter) {
+	getter := &fakeGetter{responses: responses}
+	return &WeiboService{client: getter, phoneMapping: models.DefaultPhoneMapping(), logger: slog.Default()}, getter
+}
 
 func TestWeiboService_GetUserPhoneType(t *testing.T) {
-	WeiboService := WeiboService{}
+	tests := []struct {
+		name       string
+		body       string
+		want       string
+		wantSource string
+	}{
+		{
+			name: "known brand wins over unknown source",
+			body: `{"data":{"list":[
+				{"source":"微博视频号","user":{"idstr":"42"}},
+				{"source":"iPhone 15 Pro","user":{"idstr":"42"}}]}}`,
+			want:       "苹果",
+			wantSource: "iPhone 15 Pro",
+		},
+		{
+			name:       "unknown source is kept",
+			body:       `{"data":{"list":[{"source":"微博视频号","user":{"idstr":"42"}}]}}`,
+			want:       "微博视频号",
+			wantSource: "微博视频号",
+		},
+		{
+			name:       "reposts from other users are ignored",
+			body:       `{"data":{"list":[{"source":"HUAWEI Mate 60","user":{"idstr":"7"}}]}}`,
+			want:       "未知设备",
+			wantSource: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := newTestWeiboService(map[string]string{"mymblog": tt.body})
+			got, source, err := service.GetUserPhoneType(context.Background(), "42")
+			if err != nil {
+				t.Fatalf("GetUserPhoneType() error = %v", err)
+			}
+			if got != tt.want || source != tt.wantSource {
+				t.Errorf("GetUserPhoneType() = %q, %q, want %q, %q", got, source, tt.want, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestWeiboService_CheckCookie(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"valid", `{"ok":1,"data":{"user":{"idstr":"42","screen_name":"a"}}}`, false},
+		{"expired", `{"ok":-100,"url":"https://passport.weibo.com/sso/signin"}`, true},
+		{"login page", `<!DOCTYPE html><html></html>`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := newTestWeiboService(map[string]string{"profile/info": tt.body})
+			user, err := service.CheckCookie(context.Background(), "42")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckCookie() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && utils.ExitCode(err) != utils.ExitAuth {
+				t.Errorf("ExitCode() = %d, want %d", utils.ExitCode(err), utils.ExitAuth)
+			}
+			if err == nil && user.UserName != "a" {
+				t.Errorf("CheckCookie() user = %+v", user)
+			}
+		})
+	}
+}
+
+func TestPostFilter(t *testing.T) {
+	blog := models.Blog{CommentsCount: 20, Text: "新机开箱", CreatedAt: "Mon Jan 13 12:00:00 +0800 2025"}
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter PostFilter
+		blog   models.Blog
+		want   bool
+	}{
+		{"zero filter", PostFilter{}, blog, true},
+		{"min comments", PostFilter{MinComments: 30}, blog, false},
+		{"keyword", PostFilter{Keyword: "开箱"}, blog, true},
+		{"missing keyword", PostFilter{Keyword: "评测"}, blog, false},
+		{"inside window", PostFilter{Since: since, Until: until}, blog, true},
+		{"before since", PostFilter{Since: until}, blog, false},
+		{"until is exclusive", PostFilter{Until: time.Date(2025, 1, 13, 4, 0, 0, 0, time.UTC)}, blog, false},
+		{"unparsable time", PostFilter{Since: until}, models.Blog{CreatedAt: "刚刚"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.match(tt.blog); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	older := models.Blog{CreatedAt: "Tue Dec 31 12:00:00 +0800 2024"}
+	if !(PostFilter{Since: since}).before([]models.Blog{older, older}) {
+		t.Error("before() = false for a page older than since")
+	}
+	if (PostFilter{Since: since}).before([]models.Blog{blog, older}) {
+		t.Error("before() = true for a page with newer posts")
+	}
 }
@@ -0,0 +1,572 @@
+package storage
+
+import (
+	"comment_phone_analyse/internal/models"
+	"comment_phone_analyse/internal/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // 纯 Go 实现的 SQLite 驱动，无需 CGO
+)
+
+// schema 建表语句，重复执行是安全的
+const schema = `
+CREATE TABLE IF NOT EXISTS targets (
+	uid        TEXT PRIMARY KEY,
+	first_seen TEXT NOT NULL,
+	last_seen  TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS runs (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	target_uid      TEXT NOT NULL REFERENCES targets(uid),
+	started_at      TEXT NOT NULL,
+	finished_at     TEXT,
+	limit_count     INTEGER NOT NULL DEFAULT 0,
+	sample_size     INTEGER NOT NULL DEFAULT 0,
+	sample_strategy TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS posts (
+	run_id         INTEGER NOT NULL REFERENCES runs(id),
+	id             TEXT NOT NULL DEFAULT '',
+	mblog_id       TEXT NOT NULL,
+	comments_count INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (run_id, mblog_id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+	run_id  INTEGER NOT NULL REFERENCES runs(id),
+	post_id TEXT NOT NULL, -- 博客的 mblog_id
+	user_id TEXT NOT NULL,
+	PRIMARY KEY (run_id, post_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id         TEXT PRIMARY KEY,
+	nickname   TEXT NOT NULL DEFAULT '',
+	gender     TEXT NOT NULL DEFAULT '',
+	location   TEXT NOT NULL DEFAULT '',
+	updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS device_observations (
+	run_id      INTEGER NOT NULL REFERENCES runs(id),
+	user_id     TEXT NOT NULL REFERENCES users(id),
+	brand       TEXT NOT NULL,
+	ip_location TEXT NOT NULL DEFAULT '',
+	source      TEXT NOT NULL DEFAULT '',
+	nickname    TEXT NOT NULL DEFAULT '',
+	gender      TEXT NOT NULL DEFAULT '',
+	location    TEXT NOT NULL DEFAULT '',
+	observed_at TEXT NOT NULL,
+	PRIMARY KEY (run_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS brand_counts (
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	brand  TEXT NOT NULL,
+	count  INTEGER NOT NULL,
+	PRIMARY KEY (run_id, brand)
+);
+
+CREATE TABLE IF NOT EXISTS imports (
+	source      TEXT PRIMARY KEY,
+	run_id      INTEGER NOT NULL REFERENCES runs(id),
+	imported_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_runs_target ON runs(target_uid);
+CREATE INDEX IF NOT EXISTS idx_observations_brand ON device_observations(brand);
+`
+
+// migrations 旧版数据库缺少的列，打开时自动补齐
+var migrations = []struct {
+	table, column, definition string
+}{
+	{"device_observations", "source", "TEXT NOT NULL DEFAULT ''"},
+	{"device_observations", "nickname", "TEXT NOT NULL DEFAULT ''"},
+	{"device_observations", "gender", "TEXT NOT NULL DEFAULT ''"},
+	{"device_observations", "location", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// 数据库中的时间格式
+const timeLayout = time.RFC3339
+
+// Store 基于 SQLite 的分析结果存储
+type Store struct {
+	db *sql.DB
+}
+
+// Open 打开（或创建）数据库文件并初始化表结构
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, utils.NewConfigError("创建数据库目录失败", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, utils.NewConfigError("打开数据库失败", err)
+	}
+	// SQLite 只允许单个写连接，避免并发写入时的锁冲突
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, utils.NewConfigError("初始化数据库表结构失败", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, utils.NewConfigError("升级数据库表结构失败", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// migrate 为旧版数据库补齐新增的列，并将博客与评论改为以 mblog_id 为键
+func migrate(db *sql.DB) error {
+	if err := migratePostKey(db); err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		var exists bool
+		if err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`,
+			m.table, m.column).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migratePostKey 重建以 id 为主键的旧版 posts 表，评论的 post_id 同时改为 mblog_id
+//
+// 指定博客运行时博客没有 id，旧版中这些博客与评论会合并到同一个空 id 下，无法恢复。
+func migratePostKey(db *sql.DB) error {
+	var keyed bool
+	if err := db.QueryRow(`SELECT pk > 0 FROM pragma_table_info('posts') WHERE name = 'mblog_id'`).Scan(&keyed); err != nil {
+		return err
+	}
+	if keyed {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, statement := range []string{
+		`UPDATE OR IGNORE comments SET post_id = (
+			SELECT p.mblog_id FROM posts p WHERE p.run_id = comments.run_id AND p.id = comments.post_id)
+		WHERE EXISTS (SELECT 1 FROM posts p WHERE p.run_id = comments.run_id AND p.id = comments.post_id)`,
+		`ALTER TABLE posts RENAME TO posts_old`,
+		`CREATE TABLE posts (
+			run_id         INTEGER NOT NULL REFERENCES runs(id),
+			id             TEXT NOT NULL DEFAULT '',
+			mblog_id       TEXT NOT NULL,
+			comments_count INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (run_id, mblog_id)
+		)`,
+		`INSERT OR IGNORE INTO posts (run_id, id, mblog_id, comments_count)
+			SELECT run_id, id, mblog_id, comments_count FROM posts_old`,
+		`DROP TABLE posts_old`,
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// execer *sql.DB 与 *sql.Tx 共有的写入方法，使同一语句可在事务内外执行
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// StartRun 记录一次新的分析并返回运行ID
+func (s *Store) StartRun(run models.RunInfo) (int64, error) {
+	return startRun(s.db, run)
+}
+
+func startRun(db execer, run models.RunInfo) (int64, error) {
+	now := formatTime(time.Now())
+	if _, err := db.Exec(`
+		INSERT INTO targets (uid, first_seen, last_seen) VALUES (?, ?, ?)
+		ON CONFLICT (uid) DO UPDATE SET last_seen = excluded.last_seen`,
+		run.UID, now, now); err != nil {
+		return 0, fmt.Errorf("保存目标用户失败: %w", err)
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO runs (target_uid, started_at, limit_count, sample_strategy) VALUES (?, ?, ?, ?)`,
+		run.UID, formatTime(run.StartedAt), run.Limit, run.SampleStrategy)
+	if err != nil {
+		return 0, fmt.Errorf("保存运行记录失败: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// FinishRun 更新运行的结束时间与样本量
+func (s *Store) FinishRun(runID int64, run models.RunInfo) error {
+	return finishRun(s.db, runID, run)
+}
+
+func finishRun(db execer, runID int64, run models.RunInfo) error {
+	if _, err := db.Exec(`UPDATE runs SET finished_at = ?, sample_size = ? WHERE id = ?`,
+		formatTime(run.FinishedAt), run.SampleSize, runID); err != nil {
+		return fmt.Errorf("更新运行记录失败: %w", err)
+	}
+	return nil
+}
+
+// SavePost 记录本次运行采样的博客，以 mblog_id 为键，指定博客时 id 可以为空
+func (s *Store) SavePost(runID int64, blog models.Blog) error {
+	if _, err := s.db.Exec(`
+		INSERT INTO posts (run_id, id, mblog_id, comments_count) VALUES (?, ?, ?, ?)
+		ON CONFLICT (run_id, mblog_id) DO UPDATE SET
+			id = COALESCE(NULLIF(excluded.id, ''), posts.id),
+			comments_count = excluded.comments_count`,
+		runID, blog.ID, blog.MblogID, blog.CommentsCount); err != nil {
+		return fmt.Errorf("保存博客失败: %w", err)
+	}
+	return nil
+}
+
+// SaveComments 记录博客下被采样的评论用户
+func (s *Store) SaveComments(runID int64, blog models.Blog, users []models.CommentUser) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("保存评论失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, user := range users {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO comments (run_id, post_id, user_id) VALUES (?, ?, ?)`,
+			runID, blog.MblogID, user.ID); err != nil {
+			return fmt.Errorf("保存评论失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SaveUser 更新用户的最新资料并记录本次运行观察到的设备与资料，空字段不会覆盖已有的最新资料
+func (s *Store) SaveUser(runID int64, user *models.UserInfo) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("保存用户失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveUser(tx, runID, user, formatTime(time.Now())); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func saveUser(tx execer, runID int64, user *models.UserInfo, now string) error {
+	if _, err := tx.Exec(`
+		INSERT INTO users (id, nickname, gender, location, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			nickname = COALESCE(NULLIF(excluded.nickname, ''), users.nickname),
+			gender = COALESCE(NULLIF(excluded.gender, ''), users.gender),
+			location = COALESCE(NULLIF(excluded.location, ''), users.location),
+			updated_at = excluded.updated_at`,
+		user.Id, user.UserName, user.Gender, user.Location, now); err != nil {
+		return fmt.Errorf("保存用户失败: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO device_observations (run_id, user_id, brand, ip_location, source, nickname, gender, location, observed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (run_id, user_id) DO UPDATE SET
+			brand = excluded.brand, ip_location = excluded.ip_location, source = excluded.source,
+			nickname = excluded.nickname, gender = excluded.gender, location = excluded.location,
+			observed_at = excluded.observed_at`,
+		runID, user.Id, user.PhoneType, user.IPLocation, user.Source, user.UserName, user.Gender, user.Location, now); err != nil {
+		return fmt.Errorf("保存设备记录失败: %w", err)
+	}
+	return nil
+}
+
+// SaveBrandCounts 记录只有品牌汇总、没有逐用户明细的运行（如旧版统计输出）
+func (s *Store) SaveBrandCounts(runID int64, counts map[string]int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("保存品牌汇总失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveBrandCounts(tx, runID, counts); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func saveBrandCounts(tx execer, runID int64, counts map[string]int) error {
+	for brand, count := range counts {
+		if _, err := tx.Exec(`
+			INSERT INTO brand_counts (run_id, brand, count) VALUES (?, ?, ?)
+			ON CONFLICT (run_id, brand) DO UPDATE SET count = excluded.count`,
+			runID, brand, count); err != nil {
+			return fmt.Errorf("保存品牌汇总失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordImport 记录导入来源对应的运行，用于避免重复导入
+func recordImport(db execer, source string, runID int64) error {
+	if _, err := db.Exec(`INSERT INTO imports (source, run_id, imported_at) VALUES (?, ?, ?)`,
+		source, runID, formatTime(time.Now())); err != nil {
+		return fmt.Errorf("保存导入记录失败: %w", err)
+	}
+	return nil
+}
+
+// ImportRun 在一个事务中写入导入的运行、逐用户记录或品牌汇总及导入记录，失败时不留下任何数据
+//
+// run 的样本量与起止时间在开始前确定；counts 为 nil 时只写入 users。
+func (s *Store) ImportRun(run models.RunInfo, users []models.UserInfo, counts map[string]int, source string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("导入运行失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	runID, err := startRun(tx, run)
+	if err != nil {
+		return 0, err
+	}
+	now := formatTime(time.Now())
+	for i := range users {
+		if err := saveUser(tx, runID, &users[i], now); err != nil {
+			return 0, err
+		}
+	}
+	if counts != nil {
+		if err := saveBrandCounts(tx, runID, counts); err != nil {
+			return 0, err
+		}
+	}
+	if err := finishRun(tx, runID, run); err != nil {
+		return 0, err
+	}
+	if err := recordImport(tx, source, runID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("导入运行失败: %w", err)
+	}
+	return runID, nil
+}
+
+// ImportedRun 查询导入来源对应的运行ID，未导入过时返回 0
+func (s *Store) ImportedRun(source string) (int64, error) {
+	var runID int64
+	err := s.db.QueryRow(`SELECT run_id FROM imports WHERE source = ?`, source).Scan(&runID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("查询导入记录失败: %w", err)
+	}
+	return runID, nil
+}
+
+// runColumns 查询运行记录时的列
+const runColumns = `id, target_uid, started_at, COALESCE(finished_at, ''), limit_count, sample_size, sample_strategy`
+
+// Runs 按开始时间倒序列出运行记录，uid 为空时列出全部
+func (s *Store) Runs(uid string) ([]Run, error) {
+	rows, err := s.db.Query(`SELECT `+runColumns+` FROM runs
+		WHERE ? = '' OR target_uid = ? ORDER BY started_at DESC, id DESC`, uid, uid)
+	if err != nil {
+		return nil, fmt.Errorf("查询运行记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// GetRun 查询单个运行记录
+func (s *Store) GetRun(runID int64) (*Run, error) {
+	run, err := scanRun(s.db.QueryRow(`SELECT `+runColumns+` FROM runs WHERE id = ?`, runID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.NewNotFoundError(fmt.Sprintf("运行记录 %d 不存在", runID), nil)
+	}
+	return run, err
+}
+
+// LatestRun 查询目标用户最近一次运行
+func (s *Store) LatestRun(uid string) (*Run, error) {
+	run, err := scanRun(s.db.QueryRow(`SELECT `+runColumns+` FROM runs
+		WHERE target_uid = ? ORDER BY started_at DESC, id DESC LIMIT 1`, uid))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.NewNotFoundError(fmt.Sprintf("用户 %s 没有运行记录", uid), nil)
+	}
+	return run, err
+}
+
+// scanRun 从查询结果读取运行记录
+func scanRun(row interface{ Scan(...any) error }) (*Run, error) {
+	var run Run
+	var startedAt, finishedAt string
+	if err := row.Scan(&run.ID, &run.Info.UID, &startedAt, &finishedAt,
+		&run.Info.Limit, &run.Info.SampleSize, &run.Info.SampleStrategy); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("读取运行记录失败: %w", err)
+	}
+	run.Info.StartedAt = parseTime(startedAt)
+	run.Info.FinishedAt = parseTime(finishedAt)
+	return &run, nil
+}
+
+// RunUsers 读取某次运行观察到的全部用户记录，顺序与写入顺序一致
+//
+// 资料取自该次运行当时的记录；升级前写入的记录没有资料，以 users 中的最新资料代替。
+func (s *Store) RunUsers(runID int64) ([]models.UserInfo, error) {
+	rows, err := s.db.Query(`
+		SELECT o.user_id, COALESCE(NULLIF(o.nickname, ''), u.nickname), o.brand,
+			COALESCE(NULLIF(o.location, ''), u.location), o.ip_location,
+			COALESCE(NULLIF(o.gender, ''), u.gender), o.source
+		FROM device_observations o JOIN users u ON u.id = o.user_id
+		WHERE o.run_id = ? ORDER BY o.rowid`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.UserInfo
+	for rows.Next() {
+		var user models.UserInfo
+		if err := rows.Scan(&user.Id, &user.UserName, &user.PhoneType, &user.Location, &user.IPLocation, &user.Gender, &user.Source); err != nil {
+			return nil, fmt.Errorf("读取用户记录失败: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Observations 读取所有运行的设备记录（只含用户ID、品牌与来源），uid 为空时包含全部目标用户
+func (s *Store) Observations(uid string) ([]models.UserInfo, error) {
+	rows, err := s.db.Query(`
+		SELECT o.user_id, o.brand, o.source
+		FROM device_observations o JOIN runs r ON r.id = o.run_id
+		WHERE ? = '' OR r.target_uid = ? ORDER BY o.run_id, o.rowid`, uid, uid)
+	if err != nil {
+		return nil, fmt.Errorf("查询设备记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.UserInfo
+	for rows.Next() {
+		var user models.UserInfo
+		if err := rows.Scan(&user.Id, &user.PhoneType, &user.Source); err != nil {
+			return nil, fmt.Errorf("读取设备记录失败: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// RunStatistics 根据某次运行的用户记录重新汇总统计数据，可直接交给 export 包生成摘要与图表
+//
+// 只有品牌汇总的运行仅填充品牌分布与用户数。
+func (s *Store) RunStatistics(runID int64) (*models.PhoneStatistics, error) {
+	users, err := s.RunUsers(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := models.NewPhoneStatistics()
+	for i := range users {
+		stats.Add(&users[i])
+	}
+
+	rows, err := s.db.Query(`SELECT brand, count FROM brand_counts WHERE run_id = ?`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("查询品牌汇总失败: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var brand string
+		var count int
+		if err := rows.Scan(&brand, &count); err != nil {
+			return nil, fmt.Errorf("读取品牌汇总失败: %w", err)
+		}
+		stats.BrandCounts[brand] += count
+		stats.UserCount += count
+	}
+	return stats, rows.Err()
+}
+
+// Trend 汇总目标用户每次运行的品牌统计，按开始时间排序，跳过没有用户记录的运行
+func (s *Store) Trend(uid string) ([]models.TrendPoint, error) {
+	runs, err := s.Runs(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]models.TrendPoint, 0, len(runs))
+	for i := len(runs) - 1; i >= 0; i-- {
+		stats, err := s.RunStatistics(runs[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		if stats.UserCount == 0 {
+			continue
+		}
+		points = append(points, models.TrendPoint{Time: runs[i].Info.StartedAt, Stats: stats})
+	}
+	return points, nil
+}
+
+// Run 运行记录
+type Run struct {
+	ID   int64
+	Info models.RunInfo
+}
+
+// formatTime 将时间格式化为数据库存储格式，零值存为空字符串
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(timeLayout)
+}
+
+// parseTime 解析数据库中的时间，无法解析时返回零值
+func parseTime(s string) time.Time {
+	t, err := time.Parse(timeLayout, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
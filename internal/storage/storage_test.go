@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"comment_phone_analyse/internal/models"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_RunRoundTrip(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "db", "analysis.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	started := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	runID, err := store.StartRun(models.RunInfo{UID: "100", StartedAt: started, Limit: 10, SampleStrategy: "sequential"})
+	if err != nil {
+		t.Fatalf("StartRun() error = %v", err)
+	}
+
+	blog := models.Blog{ID: "1", MblogID: "m1", CommentsCount: 5}
+	if err := store.SavePost(runID, blog); err != nil {
+		t.Fatalf("SavePost() error = %v", err)
+	}
+	if err := store.SaveComments(runID, blog, []models.CommentUser{{ID: "7"}, {ID: "8"}, {ID: "7"}}); err != nil {
+		t.Fatalf("SaveComments() error = %v", err)
+	}
+
+	users := []models.UserInfo{
+		{Id: "7", UserName: "a", PhoneType: "苹果", Gender: "f", Location: "北京 朝阳", IPLocation: "北京", Source: "iPhone 15"},
+		{Id: "8", UserName: "b", PhoneType: "华为", Gender: "m", Location: "上海", IPLocation: "上海"},
+	}
+	for i := range users {
+		if err := store.SaveUser(runID, &users[i]); err != nil {
+			t.Fatalf("SaveUser() error = %v", err)
+		}
+	}
+
+	finished := started.Add(time.Minute)
+	if err := store.FinishRun(runID, models.RunInfo{FinishedAt: finished, SampleSize: 2}); err != nil {
+		t.Fatalf("FinishRun() error = %v", err)
+	}
+
+	run, err := store.LatestRun("100")
+	if err != nil {
+		t.Fatalf("LatestRun() error = %v", err)
+	}
+	if run.ID != runID || !run.Info.StartedAt.Equal(started) || !run.Info.FinishedAt.Equal(finished) || run.Info.SampleSize != 2 {
+		t.Errorf("LatestRun() = %+v", run)
+	}
+
+	got, err := store.RunUsers(runID)
+	if err != nil {
+		t.Fatalf("RunUsers() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != users[0] || got[1] != users[1] {
+		t.Errorf("RunUsers() = %+v, want %+v", got, users)
+	}
+
+	stats, err := store.RunStatistics(runID)
+	if err != nil {
+		t.Fatalf("RunStatistics() error = %v", err)
+	}
+	if stats.UserCount != 2 || stats.BrandCounts["苹果"] != 1 || stats.GenderCounts["女"] != 1 {
+		t.Errorf("RunStatistics() = %+v", stats)
+	}
+
+	if _, err := store.GetRun(runID + 1); err == nil {
+		t.Error("GetRun() on missing run should fail")
+	}
+}
+
+func TestStore_Trend(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "analysis.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	jan := time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC)
+	for i, counts := range []map[string]int{{"苹果": 3, "华为": 1}, {}, {"苹果": 1, "华为": 3}} {
+		runID, err := store.StartRun(models.RunInfo{UID: "100", StartedAt: jan.AddDate(0, i, 0)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SaveBrandCounts(runID, counts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.StartRun(models.RunInfo{UID: "200", StartedAt: jan}); err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := store.Trend("100")
+	if err != nil {
+		t.Fatalf("Trend() error = %v", err)
+	}
+	if len(points) != 2 || !points[0].Time.Equal(jan) || points[1].Stats.BrandCounts["华为"] != 3 {
+		t.Errorf("Trend() = %+v, want two runs in time order", points)
+	}
+}
+
+func TestStore_ImportRunIsAtomic(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "analysis.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	run := models.RunInfo{UID: "100", SampleSize: 1, SampleStrategy: "legacy-brand_lines"}
+	users := []models.UserInfo{{Id: "1", PhoneType: "苹果", Source: "iPhone"}}
+	runID, err := store.ImportRun(run, users, nil, "/data/stats.txt")
+	if err != nil {
+		t.Fatalf("ImportRun() error = %v", err)
+	}
+	if imported, err := store.ImportedRun("/data/stats.txt"); err != nil || imported != runID {
+		t.Fatalf("ImportedRun() = %d, %v, want %d", imported, err, runID)
+	}
+
+	// 导入记录冲突时整个导入回滚，不留下多余的运行
+	if _, err := store.ImportRun(run, users, nil, "/data/stats.txt"); err == nil {
+		t.Fatal("ImportRun() with a duplicate source should fail")
+	}
+	runs, err := store.Runs("100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Errorf("runs = %d, want the failed import rolled back", len(runs))
+	}
+}
+
+func TestStore_RunUsersKeepProfilePerRun(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "analysis.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	profiles := []models.UserInfo{
+		{Id: "7", UserName: "old", PhoneType: "苹果", Gender: "f", Location: "北京"},
+		{Id: "7", UserName: "new", PhoneType: "华为", Gender: "f", Location: "上海"},
+	}
+	var runIDs []int64
+	for i := range profiles {
+		runID, err := store.StartRun(models.RunInfo{UID: "100"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SaveUser(runID, &profiles[i]); err != nil {
+			t.Fatal(err)
+		}
+		runIDs = append(runIDs, runID)
+	}
+
+	for i, runID := range runIDs {
+		got, err := store.RunUsers(runID)
+		if err != nil {
+			t.Fatalf("RunUsers() error = %v", err)
+		}
+		if len(got) != 1 || got[0] != profiles[i] {
+			t.Errorf("RunUsers(%d) = %+v, want %+v", runID, got, profiles[i])
+		}
+	}
+}
+
+func TestStore_GivenPostsKeepSeparateComments(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "analysis.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	runID, err := store.StartRun(models.RunInfo{UID: "100", StartedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 指定博客（post_ids）只有 mblogid，没有 id
+	for i, blog := range []models.Blog{{MblogID: "m1"}, {MblogID: "m2"}} {
+		if err := store.SavePost(runID, blog); err != nil {
+			t.Fatalf("SavePost(%s) error = %v", blog.MblogID, err)
+		}
+		users := []models.CommentUser{{ID: "7"}, {ID: fmt.Sprint(10 + i)}}
+		if err := store.SaveComments(runID, blog, users); err != nil {
+			t.Fatalf("SaveComments(%s) error = %v", blog.MblogID, err)
+		}
+	}
+
+	var posts, comments int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM posts WHERE run_id = ?`, runID).Scan(&posts); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM comments WHERE run_id = ? AND post_id IN ('m1', 'm2')`, runID).Scan(&comments); err != nil {
+		t.Fatal(err)
+	}
+	if posts != 2 || comments != 4 {
+		t.Errorf("stored %d posts and %d comments, want 2 and 4", posts, comments)
+	}
+}
+
+func TestOpen_MigratesPostKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analysis.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 旧版以 id 为主键，评论的 post_id 为博客 id
+	for _, statement := range []string{
+		`CREATE TABLE posts (run_id INTEGER NOT NULL, id TEXT NOT NULL, mblog_id TEXT NOT NULL,
+			comments_count INTEGER NOT NULL DEFAULT 0, PRIMARY KEY (run_id, id))`,
+		`CREATE TABLE comments (run_id INTEGER NOT NULL, post_id TEXT NOT NULL, user_id TEXT NOT NULL,
+			PRIMARY KEY (run_id, post_id, user_id))`,
+		schema,
+		`INSERT INTO targets VALUES ('100', '2025-01-01T00:00:00Z', '2025-01-01T00:00:00Z')`,
+		`INSERT INTO runs (id, target_uid, started_at) VALUES (1, '100', '2025-01-01T00:00:00Z')`,
+		`INSERT INTO posts VALUES (1, '1', 'm1', 5)`,
+		`INSERT INTO comments VALUES (1, '1', '7')`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	var postID string
+	if err := store.db.QueryRow(`SELECT post_id FROM comments WHERE user_id = '7'`).Scan(&postID); err != nil || postID != "m1" {
+		t.Errorf("migrated comment post_id = %q, %v, want m1", postID, err)
+	}
+	if err := store.SavePost(1, models.Blog{MblogID: "m2"}); err != nil {
+		t.Errorf("SavePost() after migration error = %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+// Package triage 汇总多次运行中未识别的设备来源，并给出可能所属品牌的建议
+package triage
+
+import (
+	"comment_phone_analyse/internal/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// 每个来源保留的示例用户数
+const maxExamples = 3
+
+// 相似度低于该值的别名不作为建议
+const minSimilarity = 0.75
+
+// modelPrefixes 常见品牌的机型代号，来源中出现形如 SM-S9180 的代号时据此推断品牌
+//
+// 前缀后须紧跟代号的字母与数字部分，避免 phone12 之类的普通单词误判。
+var modelPrefixes = []struct {
+	prefix  string
+	pattern *regexp.Regexp
+	brand   string
+}{
+	{"SM-", regexp.MustCompile(`^SM-[A-Z]\d{3}`), "三星"},
+	{"V2", regexp.MustCompile(`^V2\d{3}`), "Vivo"},
+	{"PJ", regexp.MustCompile(`^PJ[A-Z]\d{3}`), "OPPO"},
+	{"PH", regexp.MustCompile(`^PH[A-Z]\d{3}`), "OPPO"},
+	{"CPH", regexp.MustCompile(`^CPH\d{4}`), "OPPO"},
+	{"RMX", regexp.MustCompile(`^RMX\d{4}`), "真我"},
+}
+
+// Entry 单个未识别来源的汇总
+type Entry struct {
+	Source     string      `json:"source"`
+	Count      int         `json:"count"`    // 使用该来源的不同用户数
+	Examples   []string    `json:"examples"` // 示例用户ID
+	Suggestion *Suggestion `json:"suggestion,omitempty"`
+}
+
+// Suggestion 来源可能所属的品牌
+type Suggestion struct {
+	Brand  string  `json:"brand"`
+	Reason string  `json:"reason"`
+	Score  float64 `json:"score"`
+}
+
+// Build 汇总用户记录中按当前映射仍无法识别为已知品牌的来源，按用户数从多到少排列
+//
+// 同一用户在多次运行中使用相同来源只计一次；没有来源原文的记录以品牌列代替。
+func Build(users []models.UserInfo, mapping models.PhoneBrandMapping) []Entry {
+	entries := make(map[string]*Entry)
+	seen := make(map[string]map[string]bool)
+	for _, user := range users {
+		source := user.Source
+		if source == "" {
+			source = user.PhoneType
+		}
+		source = models.CleanSource(source)
+		if source == "" || source == models.UnknownDevice || models.IsKnownBrand(models.ClassifySource(mapping, source)) {
+			continue
+		}
+
+		entry := entries[source]
+		if entry == nil {
+			entry = &Entry{Source: source}
+			entries[source] = entry
+			seen[source] = make(map[string]bool)
+		}
+		if seen[source][user.Id] {
+			continue
+		}
+		seen[source][user.Id] = true
+		entry.Count++
+		if len(entry.Examples) < maxExamples {
+			entry.Examples = append(entry.Examples, user.Id)
+		}
+	}
+
+	result := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		entry.Suggestion = Suggest(entry.Source, mapping)
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Source < result[j].Source
+	})
+	return result
+}
+
+// Suggest 推断来源可能所属的品牌，优先按机型代号前缀，其次按与映射别名的相似度；无法推断时返回 nil
+func Suggest(source string, mapping models.PhoneBrandMapping) *Suggestion {
+	tokens := tokenize(source)
+
+	for _, token := range tokens {
+		upper := strings.ToUpper(token)
+		for _, rule := range modelPrefixes {
+			if len(upper) <= 10 && rule.pattern.MatchString(upper) {
+				return &Suggestion{Brand: rule.brand, Reason: fmt.Sprintf("机型代号前缀 %s", rule.prefix), Score: 1}
+			}
+		}
+	}
+
+	// 别名包括映射关键字与品牌名本身
+	aliases := make(map[string]string, len(mapping))
+	for alias, brand := range mapping {
+		if models.IsKnownBrand(brand) {
+			aliases[strings.ToLower(alias)] = brand
+		}
+	}
+	for _, brand := range mapping {
+		if models.IsKnownBrand(brand) {
+			aliases[strings.ToLower(brand)] = brand
+		}
+	}
+
+	var best *Suggestion
+	bestAlias := ""
+	for _, token := range tokens {
+		if len([]rune(token)) < 3 {
+			continue
+		}
+		for alias, brand := range aliases {
+			if len([]rune(alias)) < 3 {
+				continue
+			}
+			score := similarity(token, alias)
+			if score < minSimilarity {
+				continue
+			}
+			if best == nil || score > best.Score || score == best.Score && alias < bestAlias {
+				best = &Suggestion{Brand: brand, Reason: fmt.Sprintf("与别名 %s 相似", alias), Score: score}
+				bestAlias = alias
+			}
+		}
+	}
+	return best
+}
+
+// tokenize 将来源切分为小写的字母数字片段，保留 SM-S9180 这类带连字符的代号
+func tokenize(source string) []string {
+	return strings.FieldsFunc(strings.ToLower(source), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+}
+
+// similarity 基于编辑距离的相似度，1 表示完全相同
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein 计算两个字符串的编辑距离
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// Rules 将带建议的来源整理为品牌映射文件中的规则（来源原文 → 建议品牌）
+func Rules(entries []Entry) models.PhoneBrandMapping {
+	rules := make(models.PhoneBrandMapping)
+	for _, entry := range entries {
+		if entry.Suggestion != nil {
+			rules[entry.Source] = entry.Suggestion.Brand
+		}
+	}
+	return rules
+}
+
+// WriteRules 以品牌映射文件格式写出规则，可直接作为 brand_mapping_file 使用
+func WriteRules(w io.Writer, rules models.PhoneBrandMapping) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rules)
+}
+
+// WriteReport 以表格形式写出未识别来源报告，top 大于 0 时只输出前 top 条
+func WriteReport(w io.Writer, entries []Entry, top int) error {
+	if top > 0 && len(entries) > top {
+		entries = entries[:top]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "来源\t用户数\t示例用户\t建议品牌\t依据")
+	for _, entry := range entries {
+		brand, reason := "-", "-"
+		if entry.Suggestion != nil {
+			brand = entry.Suggestion.Brand
+			reason = fmt.Sprintf("%s (%.2f)", entry.Suggestion.Reason, entry.Suggestion.Score)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n",
+			strings.ReplaceAll(entry.Source, "\t", " "), entry.Count, strings.Join(entry.Examples, ","), brand, reason)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,72 @@
+package triage
+
+import (
+	"bytes"
+	"comment_phone_analyse/internal/models"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	mapping := models.DefaultPhoneMapping()
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"Galaxy SM-S9180", "三星"},
+		{"V2309A", "Vivo"},
+		{"PJA110", "OPPO"},
+		{"RMX3706", "真我"},
+		{"Huwei Mate", "华为"},
+		{"Xiaomy 14", "小米"},
+		{"phone12", ""},
+		{"PHB110", "OPPO"},
+		{"CPH2451", "OPPO"},
+		{"phone123", ""},
+		{"PHOTO2024", ""},
+		{"微博视频号", ""},
+	}
+
+	for _, tt := range tests {
+		got := Suggest(tt.source, mapping)
+		brand := ""
+		if got != nil {
+			brand = got.Brand
+		}
+		if brand != tt.want {
+			t.Errorf("Suggest(%q) = %+v, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	users := []models.UserInfo{
+		{Id: "1", PhoneType: "SM-S9180", Source: "SM-S9180"},
+		{Id: "1", PhoneType: "SM-S9180", Source: "SM-S9180"}, // 另一次运行中的同一用户
+		{Id: "2", PhoneType: "SM-S9180"},
+		{Id: "3", PhoneType: "苹果", Source: "iPhone 15"},
+		{Id: "4", PhoneType: "微博视频号", Source: `<a href="x">微博视频号</a>`},
+		{Id: "5", PhoneType: "未知设备"},
+	}
+
+	entries := Build(users, models.DefaultPhoneMapping())
+	if len(entries) != 2 {
+		t.Fatalf("Build() = %+v, want 2 entries", entries)
+	}
+	if entries[0].Source != "SM-S9180" || entries[0].Count != 2 || !reflect.DeepEqual(entries[0].Examples, []string{"1", "2"}) {
+		t.Errorf("entries[0] = %+v", entries[0])
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRules(&buf, Rules(entries)); err != nil {
+		t.Fatalf("WriteRules() error = %v", err)
+	}
+	var rules map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &rules); err != nil {
+		t.Fatalf("rules are not a mapping file: %v", err)
+	}
+	if !reflect.DeepEqual(rules, map[string]string{"SM-S9180": "三星"}) {
+		t.Errorf("rules = %v", rules)
+	}
+}
@@ -45,11 +45,51 @@ const (
 	ErrCodeExport    = 1007
 )
 
+// 进程退出码
+const (
+	ExitOK        = 0
+	ExitFailure   = 1 // 未分类的错误
+	ExitUsage     = 2 // 命令行参数错误
+	ExitConfig    = 3
+	ExitNetwork   = 4
+	ExitAuth      = 5
+	ExitRateLimit = 6
+	ExitParse     = 7
+	ExitNotFound  = 8
+	ExitExport    = 9
+)
+
+// exitCodes 错误码到退出码的映射
+var exitCodes = map[int]int{
+	ErrCodeConfig:    ExitConfig,
+	ErrCodeNetwork:   ExitNetwork,
+	ErrCodeAuth:      ExitAuth,
+	ErrCodeRateLimit: ExitRateLimit,
+	ErrCodeParse:     ExitParse,
+	ErrCodeNotFound:  ExitNotFound,
+	ErrCodeExport:    ExitExport,
+}
+
+// ExitCode 根据错误链中的 AppError 返回进程退出码，nil 返回 0
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		if code, ok := exitCodes[appErr.Code]; ok {
+			return code
+		}
+	}
+	return ExitFailure
+}
+
 // 预定义错误
 var (
 	ErrNoMoreData      = errors.New("没有更多数据")
 	ErrInvalidResponse = errors.New("无效的响应")
 	ErrUserNotFound    = errors.New("用户不存在")
+	ErrSchemaDrift     = errors.New("接口响应结构与预期不符")
 )
 
 // 便捷的构造函数
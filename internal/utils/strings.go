@@ -0,0 +1,14 @@
+package utils
+
+// IsNumeric 判断字符串是否为非空的纯数字，如微博用户ID
+func IsNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}